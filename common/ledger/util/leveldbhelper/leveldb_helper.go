@@ -7,7 +7,9 @@ SPDX-License-Identifier: Apache-2.0
 package leveldbhelper
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"sync"
 	"syscall"
 
@@ -226,3 +228,191 @@ func (f *FileLock) Unlock() {
 	}
 	f.db = nil
 }
+
+// rewriteBatchSize is the number of bytes of entries written per batch by RewriteDB
+const rewriteBatchSize = 4 * 1024 * 1024
+
+// RewriteDB rewrites the leveldb at dbPath so that it contains only the live entries.
+// Compaction alone does not guarantee this, as leveldb may move a table file to a
+// lower level without rewriting it, leaving the bytes of deleted and overwritten
+// entries on disk. The live entries are copied to a new db that then replaces the
+// original one. The db must not be open while it is being rewritten.
+// A rewrite interrupted by a crash is first recovered by RecoverRewrite.
+// ctx is checked between batches while the entries are copied; if it is done, the
+// partial copy is removed and the original db is left untouched. Once the copy is
+// complete, the rewrite runs to the end regardless of ctx
+func RewriteDB(ctx context.Context, dbPath string) error {
+	if err := RecoverRewrite(dbPath); err != nil {
+		return err
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		return errors.Wrapf(err, "error accessing leveldb at path [%s]", dbPath)
+	}
+	newPath := rewriteNewPath(dbPath)
+	if err := os.RemoveAll(newPath); err != nil {
+		return errors.Wrapf(err, "error removing leftover dir [%s]", newPath)
+	}
+	if err := copyLiveEntries(ctx, dbPath, newPath); err != nil {
+		if removeErr := os.RemoveAll(newPath); removeErr != nil {
+			logger.Warningf("Error removing partial copy [%s] of leveldb: %s", newPath, removeErr)
+		}
+		return err
+	}
+
+	// the original db is kept aside until the new one is in place and the
+	// completion marker records that, so that RecoverRewrite can restore it
+	// if the process dies in between
+	oldPath := rewriteOldPath(dbPath)
+	if err := os.RemoveAll(oldPath); err != nil {
+		return errors.Wrapf(err, "error removing leftover dir [%s]", oldPath)
+	}
+	if err := renameAndSync(dbPath, oldPath); err != nil {
+		return err
+	}
+	if err := renameAndSync(newPath, dbPath); err != nil {
+		return err
+	}
+	donePath := rewriteDonePath(dbPath)
+	if err := fileutil.CreateAndSyncFile(donePath, nil, 0644); err != nil {
+		return err
+	}
+	if err := fileutil.SyncParentDir(donePath); err != nil {
+		return err
+	}
+	return finishRewrite(dbPath)
+}
+
+// copyLiveEntries copies the entries of the leveldb at srcPath to a new leveldb at
+// dstPath, stopping between batches if ctx is done
+func copyLiveEntries(ctx context.Context, srcPath, dstPath string) error {
+	if err := ctx.Err(); err != nil {
+		return errors.WithMessagef(err, "rewrite of leveldb at path [%s] stopped", srcPath)
+	}
+	src, err := leveldb.OpenFile(srcPath, &opt.Options{ErrorIfMissing: true})
+	if err != nil {
+		return errors.Wrapf(err, "error opening leveldb at path [%s]", srcPath)
+	}
+	defer src.Close()
+	dst, err := leveldb.OpenFile(dstPath, &opt.Options{})
+	if err != nil {
+		return errors.Wrapf(err, "error creating leveldb at path [%s]", dstPath)
+	}
+	defer dst.Close()
+
+	itr := src.NewIterator(&goleveldbutil.Range{}, &opt.ReadOptions{})
+	defer itr.Release()
+	batch := &leveldb.Batch{}
+	batchSize := 0
+	for itr.Next() {
+		batch.Put(itr.Key(), itr.Value())
+		batchSize += len(itr.Key()) + len(itr.Value())
+		if batchSize >= rewriteBatchSize {
+			if err := dst.Write(batch, &opt.WriteOptions{}); err != nil {
+				return errors.Wrapf(err, "error writing to leveldb at path [%s]", dstPath)
+			}
+			batch.Reset()
+			batchSize = 0
+			if err := ctx.Err(); err != nil {
+				return errors.WithMessagef(err, "rewrite of leveldb at path [%s] stopped", srcPath)
+			}
+		}
+	}
+	if err := itr.Error(); err != nil {
+		return errors.Wrapf(err, "error iterating leveldb at path [%s]", srcPath)
+	}
+	if err := dst.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+		return errors.Wrapf(err, "error writing to leveldb at path [%s]", dstPath)
+	}
+
+	itr.Release()
+	if err := dst.Close(); err != nil {
+		return errors.Wrapf(err, "error closing leveldb at path [%s]", dstPath)
+	}
+	return errors.Wrapf(src.Close(), "error closing leveldb at path [%s]", srcPath)
+}
+
+// RecoverRewrite brings the leveldb at dbPath back to a consistent state after a
+// RewriteDB that was interrupted by a crash. It must be called before the db is
+// opened. If the completion marker of the rewrite exists, the rewritten db is in
+// place and the original db is removed. Otherwise the original db is moved back,
+// replacing whatever is at dbPath: that may be a rewritten copy whose rename was
+// not recorded yet, or an empty db created by a process that opened dbPath in the
+// meantime, but only the original is known to hold all the data
+func RecoverRewrite(dbPath string) error {
+	oldPath := rewriteOldPath(dbPath)
+	donePath := rewriteDonePath(dbPath)
+	oldExists, err := fileutil.DirExists(oldPath)
+	if err != nil {
+		return err
+	}
+	done, _, err := fileutil.FileExists(donePath)
+	if err != nil {
+		return err
+	}
+	if !oldExists {
+		if done {
+			return removeAndSync(donePath)
+		}
+		return nil
+	}
+	if done {
+		logger.Infof("Removing original leveldb [%s] left behind by a completed rewrite", oldPath)
+		return finishRewrite(dbPath)
+	}
+
+	logger.Warningf("Restoring leveldb at path [%s] from [%s] after an interrupted rewrite", dbPath, oldPath)
+	if err := removeAndSync(dbPath); err != nil {
+		return err
+	}
+	return renameAndSync(oldPath, dbPath)
+}
+
+// CheckNoPendingRewrite returns an error if a RewriteDB of the leveldb at dbPath
+// was interrupted and has not been recovered by RecoverRewrite yet. Opening the
+// db in that state would start from an incomplete or empty db
+func CheckNoPendingRewrite(dbPath string) error {
+	oldPath := rewriteOldPath(dbPath)
+	oldExists, err := fileutil.DirExists(oldPath)
+	if err != nil {
+		return err
+	}
+	if oldExists {
+		return errors.Errorf("leveldb at path [%s] has an interrupted rewrite, its original data is at [%s]", dbPath, oldPath)
+	}
+	return nil
+}
+
+// finishRewrite removes the original db once the completion marker is in place,
+// and then the marker itself
+func finishRewrite(dbPath string) error {
+	if err := removeAndSync(rewriteOldPath(dbPath)); err != nil {
+		return err
+	}
+	return removeAndSync(rewriteDonePath(dbPath))
+}
+
+func renameAndSync(from, to string) error {
+	if err := os.Rename(from, to); err != nil {
+		return errors.Wrapf(err, "error moving leveldb at path [%s] to [%s]", from, to)
+	}
+	return fileutil.SyncParentDir(to)
+}
+
+func removeAndSync(path string) error {
+	if err := os.RemoveAll(path); err != nil {
+		return errors.Wrapf(err, "error removing [%s]", path)
+	}
+	return fileutil.SyncParentDir(path)
+}
+
+func rewriteNewPath(dbPath string) string {
+	return dbPath + ".rewrite"
+}
+
+func rewriteOldPath(dbPath string) string {
+	return dbPath + ".old"
+}
+
+func rewriteDonePath(dbPath string) string {
+	return dbPath + ".rewritten"
+}
@@ -7,11 +7,15 @@ SPDX-License-Identifier: Apache-2.0
 package leveldbhelper
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	"github.com/syndtr/goleveldb/leveldb"
 )
@@ -185,3 +189,196 @@ func TestCreateDBInNonEmptyDir(t *testing.T) {
 	}()
 	db.Open()
 }
+
+func TestRewriteDB(t *testing.T) {
+	env := newTestDBEnv(t, testDBPath)
+	defer env.cleanup()
+	db := env.db
+	db.Open()
+
+	erasedValue := []byte("value-that-must-not-linger-on-disk-4c1f9a0e7d")
+	require.NoError(t, db.Put([]byte("key1"), erasedValue, true))
+	require.NoError(t, db.Put([]byte("key2"), []byte("value2"), true))
+	require.NoError(t, db.Delete([]byte("key1"), true))
+	db.Close()
+
+	require.NoError(t, RewriteDB(context.Background(), testDBPath))
+
+	files, err := ioutil.ReadDir(testDBPath)
+	require.NoError(t, err)
+	for _, f := range files {
+		content, err := ioutil.ReadFile(filepath.Join(testDBPath, f.Name()))
+		require.NoError(t, err)
+		require.False(t, bytes.Contains(content, erasedValue), "deleted value found in file [%s]", f.Name())
+	}
+	_, err = os.Stat(testDBPath + ".old")
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Stat(testDBPath + ".rewrite")
+	require.True(t, os.IsNotExist(err))
+
+	db.Open()
+	defer db.Close()
+	val, err := db.Get([]byte("key2"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("value2"), val)
+	val, err = db.Get([]byte("key1"))
+	require.NoError(t, err)
+	require.Nil(t, val)
+}
+
+// countdownContext is done from the n+1-th call to Err on
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (c *countdownContext) Err() error {
+	if c.n == 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestRewriteDBCancellation(t *testing.T) {
+	env := newTestDBEnv(t, testDBPath)
+	defer env.cleanup()
+	db := env.db
+	db.Open()
+	value := bytes.Repeat([]byte("v"), 1024*1024)
+	for i := 0; i < 9; i++ {
+		require.NoError(t, db.Put([]byte(fmt.Sprintf("key%d", i)), value, false))
+	}
+	db.Close()
+
+	for _, ctx := range []context.Context{
+		&countdownContext{Context: context.Background(), n: 0}, // before copying
+		&countdownContext{Context: context.Background(), n: 1}, // after the first batch
+	} {
+		err := RewriteDB(ctx, testDBPath)
+		require.EqualError(t, err, "rewrite of leveldb at path ["+testDBPath+"] stopped: context canceled")
+		require.Equal(t, context.Canceled, errors.Cause(err))
+		for _, leftover := range []string{testDBPath + ".old", testDBPath + ".rewrite", testDBPath + ".rewritten"} {
+			_, err := os.Stat(leftover)
+			require.True(t, os.IsNotExist(err), leftover)
+		}
+	}
+
+	db.Open()
+	defer db.Close()
+	for i := 0; i < 9; i++ {
+		val, err := db.Get([]byte(fmt.Sprintf("key%d", i)))
+		require.NoError(t, err)
+		require.Equal(t, value, val)
+	}
+}
+
+func TestRewriteDBMissing(t *testing.T) {
+	require.NoError(t, os.RemoveAll(testDBPath))
+	err := RewriteDB(context.Background(), testDBPath)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error accessing leveldb at path ["+testDBPath+"]")
+	_, err = os.Stat(testDBPath)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestRewriteDBRecoversInterruptedRewrite(t *testing.T) {
+	env := newTestDBEnv(t, testDBPath)
+	defer env.cleanup()
+	defer os.RemoveAll(testDBPath + ".old")
+	db := env.db
+	db.Open()
+	require.NoError(t, db.Put([]byte("key1"), []byte("value1"), true))
+	db.Close()
+
+	// simulate a crash after the original db was moved aside but before
+	// the rewritten one took its place
+	require.NoError(t, os.Rename(testDBPath, testDBPath+".old"))
+	require.NoError(t, os.MkdirAll(testDBPath+".rewrite", 0755))
+
+	// a process that opens the db in this state starts from a fresh one
+	fresh := CreateDB(&Conf{DBPath: testDBPath})
+	fresh.Open()
+	require.NoError(t, fresh.Put([]byte("stray"), []byte("value"), true))
+	fresh.Close()
+
+	// providers refuse to open it
+	_, err := NewProvider(&Conf{DBPath: testDBPath})
+	require.EqualError(t, err, "leveldb at path ["+testDBPath+"] has an interrupted rewrite, its original data is at ["+testDBPath+".old]")
+
+	// and the next rewrite restores the original data instead of
+	// discarding it as a leftover
+	require.NoError(t, RewriteDB(context.Background(), testDBPath))
+	for _, leftover := range []string{testDBPath + ".old", testDBPath + ".rewrite", testDBPath + ".rewritten"} {
+		_, err := os.Stat(leftover)
+		require.True(t, os.IsNotExist(err), leftover)
+	}
+	db.Open()
+	val, err := db.Get([]byte("key1"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("value1"), val)
+	val, err = db.Get([]byte("stray"))
+	require.NoError(t, err)
+	require.Nil(t, val)
+	db.Close()
+}
+
+func TestRecoverRewrite(t *testing.T) {
+	defer os.RemoveAll(testDBPath)
+	defer os.RemoveAll(testDBPath + ".old")
+	defer os.RemoveAll(testDBPath + ".rewritten")
+
+	// setup leaves the original db, holding "original", moved aside and a
+	// rewritten copy, holding "rewritten", at the db path
+	setup := func() {
+		for _, path := range []string{testDBPath, testDBPath + ".old", testDBPath + ".rewritten"} {
+			require.NoError(t, os.RemoveAll(path))
+		}
+		for path, key := range map[string]string{testDBPath + ".old": "original", testDBPath: "rewritten"} {
+			db := CreateDB(&Conf{DBPath: path})
+			db.Open()
+			require.NoError(t, db.Put([]byte(key), []byte("value"), true))
+			db.Close()
+		}
+	}
+	keyAtDBPath := func(key string) []byte {
+		db := CreateDB(&Conf{DBPath: testDBPath})
+		db.Open()
+		defer db.Close()
+		val, err := db.Get([]byte(key))
+		require.NoError(t, err)
+		return val
+	}
+
+	t.Run("CrashBeforeCompletionMarker", func(t *testing.T) {
+		setup()
+		require.NoError(t, RecoverRewrite(testDBPath))
+		_, err := os.Stat(testDBPath + ".old")
+		require.True(t, os.IsNotExist(err))
+		require.NotNil(t, keyAtDBPath("original"))
+		require.Nil(t, keyAtDBPath("rewritten"))
+	})
+
+	t.Run("CrashAfterCompletionMarker", func(t *testing.T) {
+		setup()
+		require.NoError(t, ioutil.WriteFile(testDBPath+".rewritten", nil, 0644))
+		require.NoError(t, RecoverRewrite(testDBPath))
+		for _, leftover := range []string{testDBPath + ".old", testDBPath + ".rewritten"} {
+			_, err := os.Stat(leftover)
+			require.True(t, os.IsNotExist(err), leftover)
+		}
+		require.Nil(t, keyAtDBPath("original"))
+		require.NotNil(t, keyAtDBPath("rewritten"))
+	})
+
+	t.Run("CrashWhileRemovingCompletionMarker", func(t *testing.T) {
+		setup()
+		require.NoError(t, os.RemoveAll(testDBPath+".old"))
+		require.NoError(t, ioutil.WriteFile(testDBPath+".rewritten", nil, 0644))
+		require.NoError(t, RecoverRewrite(testDBPath))
+		_, err := os.Stat(testDBPath + ".rewritten")
+		require.True(t, os.IsNotExist(err))
+		require.NotNil(t, keyAtDBPath("rewritten"))
+		require.NoError(t, CheckNoPendingRewrite(testDBPath))
+	})
+}
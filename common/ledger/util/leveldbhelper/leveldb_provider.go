@@ -57,6 +57,9 @@ type Provider struct {
 
 // NewProvider constructs a Provider
 func NewProvider(conf *Conf) (*Provider, error) {
+	if err := CheckNoPendingRewrite(conf.DBPath); err != nil {
+		return nil, err
+	}
 	db, err := openDBAndCheckFormat(conf)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,85 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kvledger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/pkg/errors"
+)
+
+// CompactDBs rewrites the leveldb databases of all the channels so that they no longer
+// retain the bytes of deleted or overwritten entries. The peer must be offline.
+// When the state database is CouchDB, only the leveldb databases are rewritten.
+// ctx is checked between databases and while each one is copied. When it is done,
+// the databases already rewritten stay rewritten, the one being copied is left as
+// it was, and running CompactDBs again rewrites them all
+func CompactDBs(ctx context.Context, config *ledger.Config) error {
+	rootFSPath := config.RootFSPath
+	fileLockPath := fileLockPath(rootFSPath)
+	fileLock := leveldbhelper.NewFileLock(fileLockPath)
+	if err := fileLock.Lock(); err != nil {
+		return errors.Wrap(err, "as another peer node command is executing,"+
+			" wait for that command to complete its execution or terminate it before retrying")
+	}
+	defer fileLock.Unlock()
+
+	dbPaths := compactableDBPaths(rootFSPath)
+	if config.StateDBConfig.StateDatabase == ledger.CouchDB {
+		dbPaths = dbPaths[1:]
+	}
+
+	// a db moved aside by an interrupted compaction must be restored
+	// before deciding whether it exists
+	if err := recoverInterruptedCompaction(rootFSPath); err != nil {
+		return err
+	}
+	for i, dbPath := range dbPaths {
+		if err := ctx.Err(); err != nil {
+			return errors.WithMessagef(err, "compaction stopped after %d of %d DBs, before the DB located at %s", i, len(dbPaths), dbPath)
+		}
+		if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+			logger.Infof("Skipping compaction of DB at location [%s] as it does not exist", dbPath)
+			continue
+		}
+		logger.Infof("Compacting DB at location [%s]", dbPath)
+		if err := leveldbhelper.RewriteDB(ctx, dbPath); err != nil {
+			return errors.WithMessagef(err, "error compacting the DB located at %s", dbPath)
+		}
+	}
+	return nil
+}
+
+// recoverInterruptedCompaction restores or finishes the rewrite of every leveldb
+// database that an earlier CompactDBs left half done when it was interrupted.
+// It must run before any of them is opened, while holding the file lock
+func recoverInterruptedCompaction(rootFSPath string) error {
+	for _, dbPath := range compactableDBPaths(rootFSPath) {
+		if err := leveldbhelper.RecoverRewrite(dbPath); err != nil {
+			return errors.WithMessagef(err, "error recovering the DB located at %s", dbPath)
+		}
+	}
+	return nil
+}
+
+// compactableDBPaths returns the paths of the leveldb databases that CompactDBs
+// rewrites, starting with the state database
+func compactableDBPaths(rootFSPath string) []string {
+	return []string{
+		StateDBPath(rootFSPath),
+		HistoryDBPath(rootFSPath),
+		ConfigHistoryDBPath(rootFSPath),
+		BookkeeperDBPath(rootFSPath),
+		PvtDataStorePath(rootFSPath),
+		filepath.Join(BlockStorePath(rootFSPath), blkstorage.IndexDir),
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kvledger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	configtxtest "github.com/hyperledger/fabric/common/configtx/test"
+	"github.com/hyperledger/fabric/common/ledger/blkstorage"
+	"github.com/hyperledger/fabric/core/ledger/mock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactDBs(t *testing.T) {
+	conf, cleanup := testConfig(t)
+	defer cleanup()
+	provider := testutilNewProvider(conf, t, &mock.DeployedChaincodeInfoProvider{})
+
+	numLedgers := 3
+	for i := 0; i < numLedgers; i++ {
+		genesisBlock, _ := configtxtest.MakeGenesisBlock(constructTestLedgerID(i))
+		provider.Create(genesisBlock)
+	}
+
+	// compaction should fail when provider is still open
+	err := CompactDBs(context.Background(), conf)
+	require.Error(t, err, "as another peer node command is executing, wait for that command to complete its execution or terminate it before retrying")
+	provider.Close()
+
+	// a missing db is skipped
+	require.NoError(t, os.RemoveAll(BookkeeperDBPath(conf.RootFSPath)))
+	require.NoError(t, CompactDBs(context.Background(), conf))
+
+	provider = testutilNewProvider(conf, t, &mock.DeployedChaincodeInfoProvider{})
+	defer provider.Close()
+	for i := 0; i < numLedgers; i++ {
+		l, err := provider.Open(constructTestLedgerID(i))
+		require.NoError(t, err)
+		bcInfo, err := l.GetBlockchainInfo()
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), bcInfo.Height)
+		l.Close()
+	}
+}
+
+func TestCompactDBsCancellation(t *testing.T) {
+	conf, cleanup := testConfig(t)
+	defer cleanup()
+	provider := testutilNewProvider(conf, t, &mock.DeployedChaincodeInfoProvider{})
+	genesisBlock, _ := configtxtest.MakeGenesisBlock(constructTestLedgerID(0))
+	_, err := provider.Create(genesisBlock)
+	require.NoError(t, err)
+	provider.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = CompactDBs(ctx, conf)
+	require.EqualError(t, err, "compaction stopped after 0 of 6 DBs, before the DB located at "+StateDBPath(conf.RootFSPath)+": context canceled")
+	require.Equal(t, context.Canceled, errors.Cause(err))
+
+	// the command can be run again, and the ledger is intact either way
+	require.NoError(t, CompactDBs(context.Background(), conf))
+	provider = testutilNewProvider(conf, t, &mock.DeployedChaincodeInfoProvider{})
+	defer provider.Close()
+	l, err := provider.Open(constructTestLedgerID(0))
+	require.NoError(t, err)
+	defer l.Close()
+	bcInfo, err := l.GetBlockchainInfo()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), bcInfo.Height)
+}
+
+func TestCompactDBsRestoresInterruptedCompaction(t *testing.T) {
+	conf, cleanup := testConfig(t)
+	defer cleanup()
+	provider := testutilNewProvider(conf, t, &mock.DeployedChaincodeInfoProvider{})
+	genesisBlock, _ := configtxtest.MakeGenesisBlock(constructTestLedgerID(0))
+	_, err := provider.Create(genesisBlock)
+	require.NoError(t, err)
+	provider.Close()
+
+	// simulate a crash of an earlier compaction after the private data
+	// store was moved aside but before its rewritten copy took its place
+	pvtdataStorePath := PvtDataStorePath(conf.RootFSPath)
+	require.NoError(t, os.Rename(pvtdataStorePath, pvtdataStorePath+".old"))
+
+	require.NoError(t, CompactDBs(context.Background(), conf))
+	_, err = os.Stat(pvtdataStorePath)
+	require.NoError(t, err)
+	_, err = os.Stat(pvtdataStorePath + ".old")
+	require.True(t, os.IsNotExist(err))
+
+	provider = testutilNewProvider(conf, t, &mock.DeployedChaincodeInfoProvider{})
+	defer provider.Close()
+	l, err := provider.Open(constructTestLedgerID(0))
+	require.NoError(t, err)
+	defer l.Close()
+	bcInfo, err := l.GetBlockchainInfo()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), bcInfo.Height)
+}
+
+func TestNewProviderRestoresInterruptedCompaction(t *testing.T) {
+	conf, cleanup := testConfig(t)
+	defer cleanup()
+	provider := testutilNewProvider(conf, t, &mock.DeployedChaincodeInfoProvider{})
+	genesisBlock, _ := configtxtest.MakeGenesisBlock(constructTestLedgerID(0))
+	_, err := provider.Create(genesisBlock)
+	require.NoError(t, err)
+	provider.Close()
+
+	// simulate a crash of a compaction after the block index was moved
+	// aside, followed by a peer start that must not recreate it empty
+	indexPath := filepath.Join(BlockStorePath(conf.RootFSPath), blkstorage.IndexDir)
+	require.NoError(t, os.Rename(indexPath, indexPath+".old"))
+
+	provider = testutilNewProvider(conf, t, &mock.DeployedChaincodeInfoProvider{})
+	l, err := provider.Open(constructTestLedgerID(0))
+	require.NoError(t, err)
+	_, err = l.GetBlockByNumber(0)
+	require.NoError(t, err)
+	l.Close()
+	provider.Close()
+	_, err = os.Stat(indexPath + ".old")
+	require.True(t, os.IsNotExist(err))
+
+	require.NoError(t, CompactDBs(context.Background(), conf))
+}
@@ -108,6 +108,9 @@ func NewProvider(initializer *ledger.Initializer) (pr *Provider, e error) {
 
 	p.fileLock = fileLock
 
+	if err := recoverInterruptedCompaction(initializer.Config.RootFSPath); err != nil {
+		return nil, err
+	}
 	if err := p.initLedgerIDInventory(); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,133 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockindex
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/pkg/errors"
+)
+
+// Indexer is a block index of a single channel. Blocks are committed to it
+// in order, as described for Savepoint.
+type Indexer interface {
+	Commit(block *common.Block) error
+	LastCommittedBlock() (uint64, bool, error)
+}
+
+// BlockStore is the part of the block storage needed to backfill an index.
+type BlockStore interface {
+	GetBlockchainInfo() (*common.BlockchainInfo, error)
+	RetrieveBlockByNumber(blockNum uint64) (*common.Block, error)
+}
+
+// Progress describes how far a Job has got.
+type Progress struct {
+	// Committed is the number of blocks the job has committed so far.
+	Committed uint64
+	// Next is the number of the next block to commit.
+	Next uint64
+	// Height is the height of the block store when the job started.
+	Height uint64
+	// Running is true while the job is running.
+	Running bool
+}
+
+// Job backfills an index from the block store, committing each block as it
+// goes. Since an index records the last block committed to it, a job that
+// fails or is stopped part way is resumed by running it again.
+type Job struct {
+	// Channel is the channel of the index, used in logs.
+	Channel string
+	// Index names the index in errors and logs, such as "hash index".
+	Index string
+	// Indexer is the index to backfill.
+	Indexer Indexer
+	// Store is the block store of the channel.
+	Store BlockStore
+	// Throttle is the pause between blocks, which bounds the load that the
+	// job adds to a running peer. Zero means no pause.
+	Throttle time.Duration
+
+	mutex    sync.Mutex
+	progress Progress
+}
+
+// Run brings the index up to the height of the block store, starting after
+// the last block it already holds. It returns the number of blocks
+// committed, also when it fails or ctx is done part way. Cancelling ctx is
+// how a job is aborted.
+func (j *Job) Run(ctx context.Context) (uint64, error) {
+	info, err := j.Store.GetBlockchainInfo()
+	if err != nil {
+		return 0, errors.WithMessage(err, "error retrieving blockchain info")
+	}
+	start := uint64(0)
+	savepoint, ok, err := j.Indexer.LastCommittedBlock()
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		start = savepoint + 1
+	}
+	j.update(func(p *Progress) { *p = Progress{Next: start, Height: info.Height, Running: true} })
+	defer j.update(func(p *Progress) { p.Running = false })
+
+	var committed uint64
+	for blockNum := start; blockNum < info.Height; blockNum++ {
+		if err := j.pause(ctx, blockNum > start); err != nil {
+			return committed, errors.WithMessagef(err, "backfill of %s stopped before block [%d]", j.Index, blockNum)
+		}
+		block, err := j.Store.RetrieveBlockByNumber(blockNum)
+		if err != nil {
+			return committed, errors.WithMessagef(err, "error retrieving block [%d]", blockNum)
+		}
+		if err := j.Indexer.Commit(block); err != nil {
+			return committed, err
+		}
+		committed++
+		j.update(func(p *Progress) { p.Committed, p.Next = committed, blockNum+1 })
+		// log every 1000th block at Info level so that backfill progress can be tracked
+		if blockNum%1000 == 0 {
+			logger.Infof("Channel [%s]: Backfilled %s up to block [%d] of [%d]", j.Channel, j.Index, blockNum, info.Height-1)
+		}
+	}
+	return committed, nil
+}
+
+// Progress returns the progress of the job, so that it can be monitored
+// while it runs.
+func (j *Job) Progress() Progress {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.progress
+}
+
+func (j *Job) update(f func(p *Progress)) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	f(&j.progress)
+}
+
+// pause waits for the throttle interval, if throttle is set, and returns an
+// error if ctx is done.
+func (j *Job) pause(ctx context.Context, throttle bool) error {
+	if !throttle || j.Throttle <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(j.Throttle)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return ctx.Err()
+	}
+}
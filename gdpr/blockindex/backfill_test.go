@@ -0,0 +1,133 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockindex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/gdpr/blockindex/testutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// savepointIndexer is an index that records nothing but its savepoint.
+type savepointIndexer struct {
+	levelDB   *leveldbhelper.DBHandle
+	savepoint *Savepoint
+}
+
+func (s *savepointIndexer) Commit(block *common.Block) error {
+	if commit, err := s.savepoint.Check(block.Header.Number); err != nil || !commit {
+		return err
+	}
+	batch := s.levelDB.NewUpdateBatch()
+	s.savepoint.Update(batch, block.Header.Number)
+	return s.levelDB.WriteBatch(batch, true)
+}
+
+func (s *savepointIndexer) LastCommittedBlock() (uint64, bool, error) {
+	return s.savepoint.Get()
+}
+
+func newTestJob(t *testing.T, height int) (*Job, *testutil.BlockStore, func()) {
+	levelDB, cleanup := newTestDBHandle(t)
+	store := &testutil.BlockStore{}
+	for i := 0; i < height; i++ {
+		store.Blocks = append(store.Blocks, testutil.ConstructBlock(t, uint64(i), "ns", map[string]string{"key": "value"}))
+	}
+	job := &Job{
+		Channel: "mychannel",
+		Index:   "test index",
+		Indexer: &savepointIndexer{levelDB: levelDB, savepoint: NewSavepoint(levelDB, "mychannel", "test index")},
+		Store:   store,
+	}
+	return job, store, cleanup
+}
+
+func TestJobResumes(t *testing.T) {
+	job, store, cleanup := newTestJob(t, 3)
+	defer cleanup()
+	store.FailAt = 1
+	store.FailErr = errors.New("disk on fire")
+
+	n, err := job.Run(context.Background())
+	require.EqualError(t, err, "error retrieving block [1]: disk on fire")
+	require.Equal(t, uint64(1), n)
+	require.Equal(t, Progress{Committed: 1, Next: 1, Height: 3}, job.Progress())
+
+	store.FailErr = nil
+	n, err = job.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), n)
+	require.Equal(t, Progress{Committed: 2, Next: 3, Height: 3}, job.Progress())
+
+	n, err = job.Run(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Equal(t, Progress{Next: 3, Height: 3}, job.Progress())
+}
+
+func TestJobProgress(t *testing.T) {
+	job, store, cleanup := newTestJob(t, 3)
+	defer cleanup()
+
+	var seen []Progress
+	store.Retrieved = func(blockNum uint64) {
+		seen = append(seen, job.Progress())
+	}
+	_, err := job.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []Progress{
+		{Committed: 0, Next: 0, Height: 3, Running: true},
+		{Committed: 1, Next: 1, Height: 3, Running: true},
+		{Committed: 2, Next: 2, Height: 3, Running: true},
+	}, seen)
+	require.False(t, job.Progress().Running)
+}
+
+func TestJobThrottle(t *testing.T) {
+	job, _, cleanup := newTestJob(t, 3)
+	defer cleanup()
+	job.Throttle = 20 * time.Millisecond
+
+	start := time.Now()
+	n, err := job.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), n)
+	// there is no pause before the first block
+	require.True(t, time.Since(start) >= 2*job.Throttle)
+}
+
+func TestJobCancellation(t *testing.T) {
+	job, store, cleanup := newTestJob(t, 3)
+	defer cleanup()
+	job.Throttle = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	store.Retrieved = func(blockNum uint64) {
+		if blockNum == 0 {
+			cancel()
+		}
+	}
+	n, err := job.Run(ctx)
+	require.EqualError(t, err, "backfill of test index stopped before block [1]: context canceled")
+	require.Equal(t, context.Canceled, errors.Cause(err))
+	require.Equal(t, uint64(1), n)
+
+	n, err = job.Run(ctx)
+	require.EqualError(t, err, "backfill of test index stopped before block [1]: context canceled")
+	require.Zero(t, n)
+
+	job.Throttle = 0
+	n, err = job.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), n)
+}
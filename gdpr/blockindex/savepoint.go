@@ -0,0 +1,85 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package blockindex holds what the per-channel block indexes of the gdpr
+// packages have in common: each one is built by committing the blocks of
+// its channel in order and records the last block it committed.
+package blockindex
+
+import (
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/ledger/util"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("gdpr.blockindex")
+
+var savePointKey = []byte{'s'} // a single key in db for persisting savepoint
+
+// Savepoint records the last block committed to a block index kept in a
+// leveldb handle. Blocks must be committed in order, starting from block 0,
+// and any other block is rejected so that the index cannot develop gaps; a
+// block at or below the savepoint is skipped so that recommits after a
+// crash are harmless.
+type Savepoint struct {
+	levelDB *leveldbhelper.DBHandle
+	channel string
+	index   string
+}
+
+// NewSavepoint returns the savepoint of the named index of channel, stored
+// in levelDB. The index name is only used in errors and logs.
+func NewSavepoint(levelDB *leveldbhelper.DBHandle, channel, index string) *Savepoint {
+	return &Savepoint{levelDB: levelDB, channel: channel, index: index}
+}
+
+// Get returns the number of the last block committed. The boolean is false
+// if no block has been committed yet.
+func (s *Savepoint) Get() (uint64, bool, error) {
+	raw, err := s.levelDB.Get(savePointKey)
+	if err != nil || raw == nil {
+		return 0, false, err
+	}
+	blockNum, _, err := util.DecodeOrderPreservingVarUint64(raw)
+	if err != nil {
+		return 0, false, err
+	}
+	return blockNum, true, nil
+}
+
+// Next returns the number of the block to commit next.
+func (s *Savepoint) Next() (uint64, error) {
+	savepoint, ok, err := s.Get()
+	if err != nil || !ok {
+		return 0, err
+	}
+	return savepoint + 1, nil
+}
+
+// Check reports whether blockNum is the block to commit next. It returns
+// false for a block that was already committed, and an error for a block
+// that would leave a gap.
+func (s *Savepoint) Check(blockNum uint64) (bool, error) {
+	next, err := s.Next()
+	if err != nil {
+		return false, err
+	}
+	if blockNum < next {
+		logger.Debugf("Channel [%s]: Skipping block [%d] already committed to %s", s.channel, blockNum, s.index)
+		return false, nil
+	}
+	if blockNum != next {
+		return false, errors.Errorf("cannot commit block [%d] to %s, expected block [%d]", blockNum, s.index, next)
+	}
+	return true, nil
+}
+
+// Update adds to batch the move of the savepoint to blockNum, so that it is
+// written atomically with the block's entries.
+func (s *Savepoint) Update(batch *leveldbhelper.UpdateBatch, blockNum uint64) {
+	batch.Put(savePointKey, util.EncodeOrderPreservingVarUint64(blockNum))
+}
@@ -0,0 +1,65 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockindex
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/dataformat"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDBHandle(t *testing.T) (*leveldbhelper.DBHandle, func()) {
+	path, err := ioutil.TempDir("", "blockindex")
+	require.NoError(t, err)
+	p, err := leveldbhelper.NewProvider(&leveldbhelper.Conf{DBPath: path, ExpectedFormat: dataformat.CurrentFormat})
+	require.NoError(t, err)
+	return p.GetDBHandle("mychannel"), func() {
+		p.Close()
+		os.RemoveAll(path)
+	}
+}
+
+func TestSavepoint(t *testing.T) {
+	db, cleanup := newTestDBHandle(t)
+	defer cleanup()
+	s := NewSavepoint(db, "mychannel", "test index")
+
+	_, ok, err := s.Get()
+	require.NoError(t, err)
+	require.False(t, ok)
+	next, err := s.Next()
+	require.NoError(t, err)
+	require.Zero(t, next)
+
+	_, err = s.Check(1)
+	require.EqualError(t, err, "cannot commit block [1] to test index, expected block [0]")
+	commit, err := s.Check(0)
+	require.NoError(t, err)
+	require.True(t, commit)
+
+	batch := db.NewUpdateBatch()
+	s.Update(batch, 0)
+	require.NoError(t, db.WriteBatch(batch, true))
+
+	savepoint, ok, err := s.Get()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Zero(t, savepoint)
+
+	commit, err = s.Check(0)
+	require.NoError(t, err)
+	require.False(t, commit)
+	commit, err = s.Check(1)
+	require.NoError(t, err)
+	require.True(t, commit)
+	_, err = s.Check(2)
+	require.EqualError(t, err, "cannot commit block [2] to test index, expected block [1]")
+}
@@ -0,0 +1,70 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package testutil constructs blocks and block stores for the tests of
+// block indexes.
+package testutil
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	ledgertestutil "github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/require"
+)
+
+// ConstructBlock returns a block holding one valid transaction per entry
+// of txs, each writing the given keys of namespace. An empty value is
+// written as a delete. The transactions have IDs "a", "b", and so on.
+func ConstructBlock(t *testing.T, blockNum uint64, namespace string, txs ...map[string]string) *common.Block {
+	var results [][]byte
+	var txids []string
+	for i, writes := range txs {
+		b := rwsetutil.NewRWSetBuilder()
+		for key, value := range writes {
+			var v []byte
+			if value != "" {
+				v = []byte(value)
+			}
+			b.AddToWriteSet(namespace, key, v)
+		}
+		simRes, err := b.GetTxSimulationResults()
+		require.NoError(t, err)
+		raw, err := protoutil.Marshal(simRes.PubSimulationResults)
+		require.NoError(t, err)
+		results = append(results, raw)
+		txids = append(txids, string(rune('a'+i)))
+	}
+	return ledgertestutil.ConstructBlockWithTxid(t, blockNum, nil, results, txids, false)
+}
+
+// BlockStore serves Blocks by number. Retrieving block FailAt returns
+// FailErr, if set, and Retrieved, if set, is called with the number of each
+// block retrieved.
+type BlockStore struct {
+	Blocks    []*common.Block
+	FailAt    uint64
+	FailErr   error
+	Retrieved func(blockNum uint64)
+}
+
+// GetBlockchainInfo returns the height of the store.
+func (s *BlockStore) GetBlockchainInfo() (*common.BlockchainInfo, error) {
+	return &common.BlockchainInfo{Height: uint64(len(s.Blocks))}, nil
+}
+
+// RetrieveBlockByNumber returns the block with the given number.
+func (s *BlockStore) RetrieveBlockByNumber(blockNum uint64) (*common.Block, error) {
+	if s.FailErr != nil && blockNum == s.FailAt {
+		return nil, s.FailErr
+	}
+	if s.Retrieved != nil {
+		s.Retrieved(blockNum)
+	}
+	return s.Blocks[blockNum], nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package blockscan walks the public key writes of a committed block, the
+// same way the history database does when it indexes a block.
+package blockscan
+
+import (
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/hyperledger/fabric/internal/pkg/txflags"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/pkg/errors"
+)
+
+// Write is a single key write of a valid endorser transaction.
+type Write struct {
+	BlockNum  uint64
+	TxNum     uint64
+	TxID      string
+	Namespace string
+	Key       string
+	Value     []byte
+	IsDelete  bool
+}
+
+// Writes calls visit for every public key write in the valid endorser
+// transactions of block, in block order. Transactions marked invalid in the
+// block's TRANSACTIONS_FILTER metadata and non-endorser transactions are
+// skipped, so the block must already have been validated. Iteration stops
+// at the first error returned by visit.
+func Writes(block *common.Block, visit func(w *Write) error) error {
+	blockNum := block.Header.Number
+	txsFilter := txflags.ValidationFlags(block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER])
+	if len(txsFilter) != len(block.Data.Data) {
+		return errors.Errorf("block [%d] has %d validation flags for %d transactions", blockNum, len(txsFilter), len(block.Data.Data))
+	}
+
+	for tranNo, envBytes := range block.Data.Data {
+		if txsFilter.IsInvalid(tranNo) {
+			continue
+		}
+
+		env, err := protoutil.GetEnvelopeFromBlock(envBytes)
+		if err != nil {
+			return err
+		}
+		payload, err := protoutil.UnmarshalPayload(env.Payload)
+		if err != nil {
+			return err
+		}
+		chdr, err := protoutil.UnmarshalChannelHeader(payload.Header.ChannelHeader)
+		if err != nil {
+			return err
+		}
+		if common.HeaderType(chdr.Type) != common.HeaderType_ENDORSER_TRANSACTION {
+			continue
+		}
+
+		respPayload, err := protoutil.GetActionFromEnvelope(envBytes)
+		if err != nil {
+			return err
+		}
+		txRWSet := &rwsetutil.TxRwSet{}
+		if err := txRWSet.FromProtoBytes(respPayload.Results); err != nil {
+			return err
+		}
+
+		for _, nsRWSet := range txRWSet.NsRwSets {
+			for _, kvWrite := range nsRWSet.KvRwSet.Writes {
+				w := &Write{
+					BlockNum:  blockNum,
+					TxNum:     uint64(tranNo),
+					TxID:      chdr.TxId,
+					Namespace: nsRWSet.NameSpace,
+					Key:       kvWrite.Key,
+					Value:     kvWrite.Value,
+					IsDelete:  kvWrite.IsDelete,
+				}
+				if err := visit(w); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
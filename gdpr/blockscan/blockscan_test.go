@@ -0,0 +1,95 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package blockscan
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/hyperledger/fabric/internal/pkg/txflags"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/require"
+)
+
+func simulationResults(t *testing.T, writes ...[3]string) []byte {
+	b := rwsetutil.NewRWSetBuilder()
+	for _, w := range writes {
+		var value []byte
+		if w[2] != "" {
+			value = []byte(w[2])
+		}
+		b.AddToWriteSet(w[0], w[1], value)
+	}
+	simRes, err := b.GetTxSimulationResults()
+	require.NoError(t, err)
+	raw, err := protoutil.Marshal(simRes.PubSimulationResults)
+	require.NoError(t, err)
+	return raw
+}
+
+func TestWrites(t *testing.T) {
+	var envs []*common.Envelope
+	for i, tx := range []struct {
+		txid       string
+		headerType common.HeaderType
+		results    []byte
+	}{
+		{"tx0", common.HeaderType_ENDORSER_TRANSACTION, simulationResults(t, [3]string{"ns1", "key1", "value1"}, [3]string{"ns2", "key2", ""})},
+		{"tx1", common.HeaderType_ENDORSER_TRANSACTION, simulationResults(t, [3]string{"ns1", "invalid", "value"})},
+		{"tx2", common.HeaderType_CONFIG, simulationResults(t, [3]string{"ns1", "config", "value"})},
+		{"tx3", common.HeaderType_ENDORSER_TRANSACTION, simulationResults(t, [3]string{"ns1", "key1", "value3"})},
+	} {
+		env, _, err := testutil.ConstructTransactionWithHeaderType(t, tx.results, tx.txid, false, tx.headerType)
+		require.NoError(t, err, "tx %d", i)
+		envs = append(envs, env)
+	}
+	block := testutil.NewBlock(envs, 7, nil)
+	flags := txflags.NewWithValues(4, peer.TxValidationCode_VALID)
+	flags.SetFlag(1, peer.TxValidationCode_MVCC_READ_CONFLICT)
+	block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = flags
+
+	var writes []*Write
+	err := Writes(block, func(w *Write) error {
+		writes = append(writes, w)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []*Write{
+		{BlockNum: 7, TxNum: 0, TxID: "tx0", Namespace: "ns1", Key: "key1", Value: []byte("value1")},
+		{BlockNum: 7, TxNum: 0, TxID: "tx0", Namespace: "ns2", Key: "key2", IsDelete: true},
+		{BlockNum: 7, TxNum: 3, TxID: "tx3", Namespace: "ns1", Key: "key1", Value: []byte("value3")},
+	}, writes)
+}
+
+func TestWritesStopsOnError(t *testing.T) {
+	block := testutil.ConstructBlockWithTxid(t, 1, nil,
+		[][]byte{simulationResults(t, [3]string{"ns", "a", "1"}, [3]string{"ns", "b", "2"})},
+		[]string{"tx0"}, false)
+
+	count := 0
+	err := Writes(block, func(w *Write) error {
+		count++
+		return errors.New("stop")
+	})
+	require.EqualError(t, err, "stop")
+	require.Equal(t, 1, count)
+}
+
+func TestWritesBadEnvelope(t *testing.T) {
+	block := protoutil.NewBlock(0, nil)
+	block.Data.Data = [][]byte{[]byte("garbage")}
+	err := Writes(block, func(*Write) error { return nil })
+	require.EqualError(t, err, "block [0] has 0 validation flags for 1 transactions")
+
+	block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = txflags.NewWithValues(1, peer.TxValidationCode_VALID)
+	err = Writes(block, func(*Write) error { return nil })
+	require.Error(t, err)
+}
@@ -0,0 +1,171 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package bloom provides Bloom filters over the keys written by a block so
+// that ledger-wide scans for a key, such as locating erasure targets, can
+// skip blocks that definitely do not contain it. The filters are persisted
+// per channel, one per block, in a Store.
+package bloom
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/gdpr/blockscan"
+	"github.com/pkg/errors"
+	"github.com/willf/bitset"
+)
+
+// Filter is a Bloom filter. A negative answer from MayContain is definite, a
+// positive one is wrong with a probability that depends on how the filter
+// was sized.
+type Filter struct {
+	bits   *bitset.BitSet
+	m      uint64
+	hashes uint32
+}
+
+// New returns an empty filter of m bits using the given number of hash
+// functions.
+func New(m uint64, hashes uint32) (*Filter, error) {
+	if m == 0 {
+		return nil, errors.New("filter size must be greater than zero")
+	}
+	if hashes == 0 {
+		return nil, errors.New("number of hash functions must be greater than zero")
+	}
+	return &Filter{bits: bitset.New(uint(m)), m: m, hashes: hashes}, nil
+}
+
+// NewWithEstimates returns an empty filter sized to hold n items with a
+// false positive rate of at most fpRate.
+func NewWithEstimates(n uint64, fpRate float64) (*Filter, error) {
+	if fpRate <= 0 || fpRate >= 1 {
+		return nil, errors.Errorf("false positive rate must be in (0, 1), got %v", fpRate)
+	}
+	if n == 0 {
+		n = 1
+	}
+	m := math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := math.Max(1, math.Round(m/float64(n)*math.Ln2))
+	return New(uint64(m), uint32(k))
+}
+
+// Add adds data to the filter.
+func (f *Filter) Add(data []byte) {
+	h1, h2 := baseHashes(data)
+	for i := uint32(0); i < f.hashes; i++ {
+		f.bits.Set(f.location(h1, h2, i))
+	}
+}
+
+// MayContain reports whether data may have been added to the filter. A
+// false result means it was definitely not added.
+func (f *Filter) MayContain(data []byte) bool {
+	h1, h2 := baseHashes(data)
+	for i := uint32(0); i < f.hashes; i++ {
+		if !f.bits.Test(f.location(h1, h2, i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Bytes returns the encoding of the filter: the bit count and the number of
+// hash functions, followed by the bitset.
+func (f *Filter) Bytes() ([]byte, error) {
+	bits, err := f.bits.MarshalBinary()
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling filter bits")
+	}
+	raw := make([]byte, 12, 12+len(bits))
+	binary.BigEndian.PutUint64(raw, f.m)
+	binary.BigEndian.PutUint32(raw[8:], f.hashes)
+	return append(raw, bits...), nil
+}
+
+// FromBytes decodes a filter encoded with Bytes. The declared sizes are
+// checked against the length of the encoding before anything is allocated,
+// so a corrupted header cannot trigger a huge allocation.
+func FromBytes(raw []byte) (*Filter, error) {
+	if len(raw) < 20 {
+		return nil, errors.Errorf("filter encoding too short: %d bytes", len(raw))
+	}
+	m := binary.BigEndian.Uint64(raw)
+	hashes := binary.BigEndian.Uint32(raw[8:])
+	if m == 0 {
+		return nil, errors.New("filter size must be greater than zero")
+	}
+	if hashes == 0 {
+		return nil, errors.New("number of hash functions must be greater than zero")
+	}
+	payload := raw[12:]
+	if m > uint64(len(payload)-8)*8 {
+		return nil, errors.Errorf("filter declares %d bits but the encoding holds at most %d", m, uint64(len(payload)-8)*8)
+	}
+	// the bitset encoding starts with its own big-endian bit count, from
+	// which it sizes its allocation
+	if encoded := binary.BigEndian.Uint64(payload); encoded != m {
+		return nil, errors.Errorf("filter declares %d bits but encodes %d", m, encoded)
+	}
+	if words := (m + 63) / 64; uint64(len(payload)) != 8+8*words {
+		return nil, errors.Errorf("filter of %d bits needs %d bytes of bits, got %d", m, 8+8*words, len(payload))
+	}
+
+	bits := &bitset.BitSet{}
+	if err := bits.UnmarshalBinary(payload); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling filter bits")
+	}
+	if uint64(bits.Len()) != m {
+		return nil, errors.Errorf("filter declares %d bits but encodes %d", m, bits.Len())
+	}
+	return &Filter{bits: bits, m: m, hashes: hashes}, nil
+}
+
+// location returns the i-th probe position using double hashing
+// (Kirsch and Mitzenmacher).
+func (f *Filter) location(h1, h2 uint64, i uint32) uint {
+	return uint((h1 + uint64(i)*h2) % f.m)
+}
+
+func baseHashes(data []byte) (uint64, uint64) {
+	digest := sha256.Sum256(data)
+	return binary.BigEndian.Uint64(digest[:8]), binary.BigEndian.Uint64(digest[8:16]) | 1
+}
+
+// KeyEntry returns the filter entry for a key in a namespace. Namespace
+// names cannot contain a nil byte, so the separator is unambiguous even for
+// composite keys.
+func KeyEntry(namespace, key string) []byte {
+	entry := make([]byte, 0, len(namespace)+1+len(key))
+	entry = append(entry, namespace...)
+	entry = append(entry, 0)
+	return append(entry, key...)
+}
+
+// ForBlock builds a filter over every key written by the valid transactions
+// of block, sized for the given false positive rate.
+func ForBlock(block *common.Block, fpRate float64) (*Filter, error) {
+	var entries [][]byte
+	err := blockscan.Writes(block, func(w *blockscan.Write) error {
+		entries = append(entries, KeyEntry(w.Namespace, w.Key))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "error scanning block [%d]", block.Header.Number)
+	}
+
+	f, err := NewWithEstimates(uint64(len(entries)), fpRate)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		f.Add(e)
+	}
+	return f, nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	f, err := NewWithEstimates(1000, 0.01)
+	require.NoError(t, err)
+
+	for i := 0; i < 1000; i++ {
+		f.Add([]byte(fmt.Sprintf("member-%d", i)))
+	}
+	for i := 0; i < 1000; i++ {
+		require.True(t, f.MayContain([]byte(fmt.Sprintf("member-%d", i))))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if f.MayContain([]byte(fmt.Sprintf("absent-%d", i))) {
+			falsePositives++
+		}
+	}
+	require.True(t, falsePositives < 300, "%d false positives out of 10000", falsePositives)
+}
+
+func TestNewErrors(t *testing.T) {
+	_, err := New(0, 1)
+	require.EqualError(t, err, "filter size must be greater than zero")
+	_, err = New(8, 0)
+	require.EqualError(t, err, "number of hash functions must be greater than zero")
+	_, err = NewWithEstimates(10, 0)
+	require.EqualError(t, err, "false positive rate must be in (0, 1), got 0")
+	_, err = NewWithEstimates(10, 1)
+	require.EqualError(t, err, "false positive rate must be in (0, 1), got 1")
+
+	f, err := NewWithEstimates(0, 0.5)
+	require.NoError(t, err)
+	require.False(t, f.MayContain([]byte("anything")))
+}
+
+func TestSerialization(t *testing.T) {
+	f, err := New(500, 4)
+	require.NoError(t, err)
+	f.Add([]byte("a"))
+	f.Add([]byte("b"))
+
+	raw, err := f.Bytes()
+	require.NoError(t, err)
+	decoded, err := FromBytes(raw)
+	require.NoError(t, err)
+	require.True(t, decoded.MayContain([]byte("a")))
+	require.True(t, decoded.MayContain([]byte("b")))
+	require.Equal(t, f, decoded)
+
+	_, err = FromBytes(raw[:5])
+	require.EqualError(t, err, "filter encoding too short: 5 bytes")
+	_, err = FromBytes(raw[:12])
+	require.Error(t, err)
+
+	other, err := New(600, 4)
+	require.NoError(t, err)
+	otherRaw, err := other.Bytes()
+	require.NoError(t, err)
+	_, err = FromBytes(append(raw[:12:12], otherRaw[12:]...))
+	require.EqualError(t, err, "filter declares 500 bits but encodes 600")
+	_, err = FromBytes(append(raw, 0, 0, 0, 0, 0, 0, 0, 0))
+	require.EqualError(t, err, "filter of 500 bits needs 72 bytes of bits, got 80")
+}
+
+func TestFromBytesRejectsOversizedHeaders(t *testing.T) {
+	f, err := New(64, 2)
+	require.NoError(t, err)
+	raw, err := f.Bytes()
+	require.NoError(t, err)
+	require.Len(t, raw, 28)
+
+	// a header declaring 2^42 bits must fail without allocating them
+	huge := append([]byte{}, raw...)
+	binary.BigEndian.PutUint64(huge, 1<<42)
+	_, err = FromBytes(huge)
+	require.EqualError(t, err, "filter declares 4398046511104 bits but the encoding holds at most 64")
+
+	// so must a bitset header that disagrees with a plausible filter header
+	huge = append([]byte{}, raw...)
+	binary.BigEndian.PutUint64(huge[12:], 1<<42)
+	_, err = FromBytes(huge)
+	require.EqualError(t, err, "filter declares 64 bits but encodes 4398046511104")
+
+	zero := append([]byte{}, raw...)
+	binary.BigEndian.PutUint32(zero[8:], 0)
+	_, err = FromBytes(zero)
+	require.EqualError(t, err, "number of hash functions must be greater than zero")
+}
+
+func TestKeyEntry(t *testing.T) {
+	require.Equal(t, []byte("ns\x00key"), KeyEntry("ns", "key"))
+}
+
+func TestForBlock(t *testing.T) {
+	b := rwsetutil.NewRWSetBuilder()
+	b.AddToWriteSet("marbles", "marble1", []byte("blue"))
+	b.AddToWriteSet("marbles", "marble2", nil)
+	b.AddToWriteSet("fabcar", "CAR0", []byte("toyota"))
+	simRes, err := b.GetTxSimulationResults()
+	require.NoError(t, err)
+	results, err := protoutil.Marshal(simRes.PubSimulationResults)
+	require.NoError(t, err)
+
+	block := testutil.ConstructBlockWithTxid(t, 3, nil, [][]byte{results}, []string{"tx0"}, false)
+	f, err := ForBlock(block, 0.001)
+	require.NoError(t, err)
+	require.True(t, f.MayContain(KeyEntry("marbles", "marble1")))
+	require.True(t, f.MayContain(KeyEntry("marbles", "marble2")))
+	require.True(t, f.MayContain(KeyEntry("fabcar", "CAR0")))
+	require.False(t, f.MayContain(KeyEntry("fabcar", "marble1")))
+
+	block.Data.Data = append(block.Data.Data, []byte("garbage"))
+	_, err = ForBlock(block, 0.001)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error scanning block [3]")
+}
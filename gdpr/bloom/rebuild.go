@@ -0,0 +1,30 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package bloom
+
+import (
+	"context"
+
+	"github.com/hyperledger/fabric/gdpr/blockindex"
+)
+
+// BackfillJob returns a job that builds the filters of the blocks of store
+// that are not in the filter store yet.
+func (s *Store) BackfillJob(store blockindex.BlockStore) *blockindex.Job {
+	return &blockindex.Job{
+		Channel: s.name,
+		Index:   "bloom filter store",
+		Indexer: s,
+		Store:   store,
+	}
+}
+
+// Rebuild runs the backfill job of s without throttling. It returns the
+// number of filters built, as described for blockindex.Job.Run.
+func Rebuild(ctx context.Context, s *Store, store blockindex.BlockStore) (uint64, error) {
+	return s.BackfillJob(store).Run(ctx)
+}
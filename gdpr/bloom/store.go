@@ -0,0 +1,154 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package bloom
+
+import (
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/ledger/dataformat"
+	"github.com/hyperledger/fabric/common/ledger/util"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/gdpr/blockindex"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("gdpr.bloom")
+
+var filterKeyPrefix = []byte{'f'} // prefix added to the block number of a persisted filter
+
+// Provider provides handles to the persisted per-block filters of each
+// channel.
+type Provider struct {
+	leveldbProvider *leveldbhelper.Provider
+	fpRate          float64
+}
+
+// NewProvider instantiates a Provider storing its data at path. Filters are
+// sized for the given false positive rate.
+func NewProvider(path string, fpRate float64) (*Provider, error) {
+	if fpRate <= 0 || fpRate >= 1 {
+		return nil, errors.Errorf("false positive rate must be in (0, 1), got %v", fpRate)
+	}
+	logger.Debugf("constructing bloom filter store provider dbPath=%s", path)
+	levelDBProvider, err := leveldbhelper.NewProvider(
+		&leveldbhelper.Conf{
+			DBPath:         path,
+			ExpectedFormat: dataformat.CurrentFormat,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{leveldbProvider: levelDBProvider, fpRate: fpRate}, nil
+}
+
+// GetStore returns the filter store of the named channel.
+func (p *Provider) GetStore(channel string) *Store {
+	levelDB := p.leveldbProvider.GetDBHandle(channel)
+	return &Store{
+		levelDB:   levelDB,
+		savepoint: blockindex.NewSavepoint(levelDB, channel, "bloom filter store"),
+		name:      channel,
+		fpRate:    p.fpRate,
+	}
+}
+
+// Drop drops the filter store of the named channel.
+func (p *Provider) Drop(channel string) error {
+	return p.leveldbProvider.Drop(channel)
+}
+
+// Close closes the underlying db.
+func (p *Provider) Close() {
+	p.leveldbProvider.Close()
+}
+
+// Store holds one filter per block of a single channel.
+type Store struct {
+	levelDB   *leveldbhelper.DBHandle
+	savepoint *blockindex.Savepoint
+	name      string
+	fpRate    float64
+}
+
+// Commit builds and persists the filter of block. Blocks are committed as
+// described for blockindex.Savepoint.
+func (s *Store) Commit(block *common.Block) error {
+	blockNum := block.Header.Number
+	if commit, err := s.savepoint.Check(blockNum); err != nil || !commit {
+		return err
+	}
+
+	f, err := ForBlock(block, s.fpRate)
+	if err != nil {
+		return err
+	}
+	raw, err := f.Bytes()
+	if err != nil {
+		return err
+	}
+	batch := s.levelDB.NewUpdateBatch()
+	batch.Put(constructFilterKey(blockNum), raw)
+	s.savepoint.Update(batch, blockNum)
+	if err := s.levelDB.WriteBatch(batch, true); err != nil {
+		return err
+	}
+	logger.Debugf("Channel [%s]: Bloom filter committed for blockNo [%d]", s.name, blockNum)
+	return nil
+}
+
+// Filter returns the filter of the given block, or nil if the block has not
+// been committed to the store.
+func (s *Store) Filter(blockNum uint64) (*Filter, error) {
+	raw, err := s.levelDB.Get(constructFilterKey(blockNum))
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	f, err := FromBytes(raw)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "error decoding bloom filter of block [%d]", blockNum)
+	}
+	return f, nil
+}
+
+// CandidateBlocks returns, in ascending order, the committed blocks whose
+// filter may contain a write to key in namespace. Every other committed
+// block definitely does not write it.
+func (s *Store) CandidateBlocks(namespace, key string) ([]uint64, error) {
+	itr, err := s.levelDB.GetIterator(filterKeyPrefix, []byte{filterKeyPrefix[0] + 1})
+	if err != nil {
+		return nil, err
+	}
+	defer itr.Release()
+
+	entry := KeyEntry(namespace, key)
+	var blocks []uint64
+	for itr.Next() {
+		blockNum, _, err := util.DecodeOrderPreservingVarUint64(itr.Key()[len(filterKeyPrefix):])
+		if err != nil {
+			return nil, err
+		}
+		f, err := FromBytes(itr.Value())
+		if err != nil {
+			return nil, errors.WithMessagef(err, "error decoding bloom filter of block [%d]", blockNum)
+		}
+		if f.MayContain(entry) {
+			blocks = append(blocks, blockNum)
+		}
+	}
+	return blocks, itr.Error()
+}
+
+// LastCommittedBlock returns the number of the last block committed to the
+// store. The boolean is false if no block has been committed yet.
+func (s *Store) LastCommittedBlock() (uint64, bool, error) {
+	return s.savepoint.Get()
+}
+
+func constructFilterKey(blockNum uint64) []byte {
+	return append(append([]byte{}, filterKeyPrefix...), util.EncodeOrderPreservingVarUint64(blockNum)...)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package bloom
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/gdpr/blockindex"
+	"github.com/hyperledger/fabric/gdpr/blockindex/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T) (*Store, func()) {
+	path, err := ioutil.TempDir("", "bloomstore")
+	require.NoError(t, err)
+	p, err := NewProvider(path, 0.0001)
+	require.NoError(t, err)
+	return p.GetStore("mychannel"), func() {
+		p.Close()
+		os.RemoveAll(path)
+	}
+}
+
+// constructBlock returns a block with a single transaction writing keys.
+func constructBlock(t *testing.T, blockNum uint64, keys ...string) *common.Block {
+	writes := map[string]string{}
+	for _, k := range keys {
+		writes[k] = "value"
+	}
+	return testutil.ConstructBlock(t, blockNum, "marbles", writes)
+}
+
+func TestStore(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	_, ok, err := s.LastCommittedBlock()
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, s.Commit(constructBlock(t, 0, "marble1")))
+	require.NoError(t, s.Commit(constructBlock(t, 1, "marble2")))
+	require.NoError(t, s.Commit(constructBlock(t, 2, "marble1", "marble3")))
+
+	savepoint, ok, err := s.LastCommittedBlock()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(2), savepoint)
+
+	blocks, err := s.CandidateBlocks("marbles", "marble1")
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 2}, blocks)
+	blocks, err = s.CandidateBlocks("marbles", "marble4")
+	require.NoError(t, err)
+	require.Empty(t, blocks)
+
+	f, err := s.Filter(1)
+	require.NoError(t, err)
+	require.True(t, f.MayContain(KeyEntry("marbles", "marble2")))
+	f, err = s.Filter(3)
+	require.NoError(t, err)
+	require.Nil(t, f)
+
+	// recommitting an already stored block is a no-op
+	require.NoError(t, s.Commit(constructBlock(t, 1, "marble4")))
+	blocks, err = s.CandidateBlocks("marbles", "marble4")
+	require.NoError(t, err)
+	require.Empty(t, blocks)
+}
+
+func TestStoreRejectsGaps(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	err := s.Commit(constructBlock(t, 1, "marble1"))
+	require.EqualError(t, err, "cannot commit block [1] to bloom filter store, expected block [0]")
+
+	require.NoError(t, s.Commit(constructBlock(t, 0, "marble1")))
+	err = s.Commit(constructBlock(t, 2, "marble1"))
+	require.EqualError(t, err, "cannot commit block [2] to bloom filter store, expected block [1]")
+
+	savepoint, _, err := s.LastCommittedBlock()
+	require.NoError(t, err)
+	require.Zero(t, savepoint)
+}
+
+func TestRebuild(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+	require.NoError(t, s.Commit(constructBlock(t, 0, "marble1")))
+
+	store := &testutil.BlockStore{
+		Blocks: []*common.Block{
+			constructBlock(t, 0, "marble1"),
+			constructBlock(t, 1, "marble2"),
+			constructBlock(t, 2, "marble1"),
+		},
+	}
+	n, err := Rebuild(context.Background(), s, store)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), n)
+
+	blocks, err := s.CandidateBlocks("marbles", "marble1")
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0, 2}, blocks)
+
+	job := s.BackfillJob(store)
+	n, err = job.Run(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Equal(t, blockindex.Progress{Next: 3, Height: 3}, job.Progress())
+}
+
+func TestNewProviderErrors(t *testing.T) {
+	_, err := NewProvider("unused", 0)
+	require.EqualError(t, err, "false positive rate must be in (0, 1), got 0")
+}
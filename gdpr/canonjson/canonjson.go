@@ -0,0 +1,301 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package canonjson serializes JSON values canonically, following the JSON
+// Canonicalization Scheme (RFC 8785), so that endorsers in different
+// organizations hash byte-identical representations of logically equal
+// JSON values regardless of key order, whitespace or number formatting
+// produced by their chaincode.
+package canonjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Canonicalize returns the canonical serialization of the JSON document in
+// input. Objects with duplicate member names, invalid UTF-8, escapes of
+// lone surrogates, trailing data and numbers that overflow an IEEE 754
+// double are rejected, since any of them would let two endorsers disagree
+// on what the document means. So are numbers of magnitude 2^53 or more
+// that a double cannot represent exactly: at that magnitude every double
+// is an integer, and rounding would make distinct integers canonicalize
+// identically. Smaller fractional numbers are rounded to the nearest
+// double, as RFC 8785 specifies.
+func Canonicalize(input []byte) ([]byte, error) {
+	if !utf8.Valid(input) {
+		return nil, errors.New("input is not valid UTF-8")
+	}
+	if err := checkSurrogates(input); err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(input))
+	dec.UseNumber()
+
+	var buf bytes.Buffer
+	if err := writeValue(&buf, dec); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after top-level value")
+	}
+	return buf.Bytes(), nil
+}
+
+// Marshal returns the canonical JSON encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling value")
+	}
+	return Canonicalize(raw)
+}
+
+// Equal reports whether two JSON documents are logically equal, that is,
+// whether their canonical serializations are identical.
+func Equal(a, b []byte) (bool, error) {
+	ca, err := Canonicalize(a)
+	if err != nil {
+		return false, err
+	}
+	cb, err := Canonicalize(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ca, cb), nil
+}
+
+func writeValue(buf *bytes.Buffer, dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return errors.Wrap(err, "invalid JSON")
+	}
+	return writeToken(buf, dec, tok)
+}
+
+func writeToken(buf *bytes.Buffer, dec *json.Decoder, tok json.Token) error {
+	switch v := tok.(type) {
+	case json.Delim:
+		switch v {
+		case '{':
+			return writeObject(buf, dec)
+		case '[':
+			return writeArray(buf, dec)
+		default:
+			return errors.Errorf("invalid JSON: unexpected %s", v)
+		}
+	case string:
+		writeString(buf, v)
+	case json.Number:
+		s, err := formatNumber(v)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case nil:
+		buf.WriteString("null")
+	default:
+		return errors.Errorf("invalid JSON: unexpected token %v", tok)
+	}
+	return nil
+}
+
+type member struct {
+	name  string
+	value []byte
+}
+
+func writeObject(buf *bytes.Buffer, dec *json.Decoder) error {
+	var members []member
+	seen := map[string]struct{}{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return errors.Wrap(err, "invalid JSON")
+		}
+		name, ok := tok.(string)
+		if !ok {
+			return errors.Errorf("invalid JSON: object member name %v is not a string", tok)
+		}
+		if _, ok := seen[name]; ok {
+			return errors.Errorf("duplicate object member %q", name)
+		}
+		seen[name] = struct{}{}
+
+		var value bytes.Buffer
+		if err := writeValue(&value, dec); err != nil {
+			return err
+		}
+		members = append(members, member{name: name, value: value.Bytes()})
+	}
+	if _, err := dec.Token(); err != nil {
+		return errors.Wrap(err, "invalid JSON")
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return lessUTF16(members[i].name, members[j].name)
+	})
+
+	buf.WriteByte('{')
+	for i, m := range members {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeString(buf, m.name)
+		buf.WriteByte(':')
+		buf.Write(m.value)
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func writeArray(buf *bytes.Buffer, dec *json.Decoder) error {
+	buf.WriteByte('[')
+	for first := true; dec.More(); first = false {
+		if !first {
+			buf.WriteByte(',')
+		}
+		if err := writeValue(buf, dec); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return errors.Wrap(err, "invalid JSON")
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+// writeString escapes only what JSON requires: the quotation mark, the
+// reverse solidus and control characters. Everything else is emitted as
+// UTF-8.
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte("0123456789abcdef"[r>>4])
+				buf.WriteByte("0123456789abcdef"[r&0xf])
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// checkSurrogates rejects \u escapes of UTF-16 surrogates that are not part
+// of a surrogate pair. The decoder would replace them with U+FFFD, which
+// would make them indistinguishable from an actual U+FFFD.
+func checkSurrogates(input []byte) error {
+	inString := false
+	for i := 0; i < len(input); i++ {
+		switch {
+		case input[i] == '"':
+			inString = !inString
+		case inString && input[i] == '\\':
+			r, ok := escapedRune(input, i)
+			switch {
+			case !ok:
+				i++
+			case utf16.IsSurrogate(r):
+				r2, ok := escapedRune(input, i+6)
+				if !ok || utf16.DecodeRune(r, r2) == utf8.RuneError {
+					return errors.Errorf("invalid JSON: lone surrogate escape %s", input[i:i+6])
+				}
+				i += 11
+			default:
+				i += 5
+			}
+		}
+	}
+	return nil
+}
+
+// escapedRune decodes the \uXXXX escape at input[i:], if there is one.
+func escapedRune(input []byte, i int) (rune, bool) {
+	if i+6 > len(input) || input[i] != '\\' || input[i+1] != 'u' {
+		return 0, false
+	}
+	r, err := strconv.ParseUint(string(input[i+2:i+6]), 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	return rune(r), true
+}
+
+// formatNumber serializes a number the way ECMAScript's Number.prototype.toString
+// does, which is what RFC 8785 mandates.
+func formatNumber(n json.Number) (string, error) {
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", errors.Errorf("number %s cannot be represented as an IEEE 754 double", n)
+	}
+	// The exponent of a number this large is bounded by its own length and
+	// the range of a double, so the exact comparison stays cheap.
+	if math.Abs(f) >= 1<<53 {
+		exact, ok := new(big.Rat).SetString(string(n))
+		if !ok || exact.Cmp(new(big.Rat).SetFloat64(f)) != 0 {
+			return "", errors.Errorf("number %s cannot be represented exactly as an IEEE 754 double", n)
+		}
+	}
+	if f == 0 {
+		return "0", nil
+	}
+
+	abs := math.Abs(f)
+	if abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+
+	// ECMAScript writes exponents without leading zeros, e.g. 1e-7 rather
+	// than 1e-07.
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	i := strings.IndexByte(s, 'e')
+	mantissa, exp := s[:i], s[i+2:]
+	sign := s[i+1]
+	exp = strings.TrimLeft(exp, "0")
+	return mantissa + "e" + string(sign) + exp, nil
+}
+
+// lessUTF16 orders strings by their UTF-16 code units, as RFC 8785 requires.
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
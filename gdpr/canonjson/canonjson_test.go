@@ -0,0 +1,115 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package canonjson
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{"Whitespace", " { \"b\" : 1 ,\n \"a\" : [ true , false , null ] } ", `{"a":[true,false,null],"b":1}`},
+		{"NestedOrdering", `{"z":{"y":1,"x":2},"a":[{"d":1,"c":2}]}`, `{"a":[{"c":2,"d":1}],"z":{"x":2,"y":1}}`},
+		{"EmptyContainers", `{"a":{},"b":[]}`, `{"a":{},"b":[]}`},
+		{"Scalars", `"text"`, `"text"`},
+		// from RFC 8785 section 3.2.3
+		{"UTF16Ordering", `{"\u20ac":"Euro Sign","\r":"Carriage Return","\ufb33":"Hebrew Letter Dalet With Dagesh","1":"One","\ud83d\ude00":"Emoji: Grinning Face","\u0080":"Control","\u00f6":"Latin Small Letter O With Diaeresis"}`,
+			"{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\",\"\U0001F600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}"},
+		{"StringEscaping", `"\u0041\u00e9\/\u2028<>&\u001f\"\\\b\f\n\r\t"`, "\"A\u00e9/\u2028<>&\\u001f\\\"\\\\\\b\\f\\n\\r\\t\""},
+		{"Integers", `[0, -0, 1, -1, 100, 1E2, 9007199254740991]`, `[0,0,1,-1,100,100,9007199254740991]`},
+		{"Decimals", `[1.0, 1.50, 0.000001, 123.456e1, -3.0e-1]`, `[1,1.5,0.000001,1234.56,-0.3]`},
+		{"Exponents", `[1e21, 1e-7, 1.5e22, -2.5e-10, 1e22]`, `[1e+21,1e-7,1.5e+22,-2.5e-10,1e+22]`},
+		{"ExactLargeIntegers", `[9007199254740992, -9007199254740994, 18014398509481988, 9007199254740992.0, 9.007199254740994e15]`, `[9007199254740992,-9007199254740994,18014398509481988,9007199254740992,9007199254740994]`},
+		{"PairedSurrogates", `"\ud83d\ude00 \uFFFD"`, "\"\U0001F600 \uFFFD\""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Canonicalize([]byte(tt.input))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(out))
+
+			again, err := Canonicalize(out)
+			require.NoError(t, err)
+			require.Equal(t, out, again, "canonicalization must be idempotent")
+		})
+	}
+}
+
+func TestCanonicalizeErrors(t *testing.T) {
+	tests := []struct {
+		name, input, expectedErr string
+	}{
+		{"DuplicateMember", `{"a":1,"b":2,"a":3}`, `duplicate object member "a"`},
+		{"NestedDuplicate", `[{"x":{"k":1,"k":1}}]`, `duplicate object member "k"`},
+		{"InvalidUTF8", "\"\xff\"", "input is not valid UTF-8"},
+		{"NumberOverflow", `1e400`, "number 1e400 cannot be represented as an IEEE 754 double"},
+		{"TrailingData", `{} {}`, "unexpected data after top-level value"},
+		{"InexactInteger", `{"n":9007199254740993}`, "number 9007199254740993 cannot be represented exactly as an IEEE 754 double"},
+		{"InexactLargeInteger", `12345678901234567891`, "number 12345678901234567891 cannot be represented exactly as an IEEE 754 double"},
+		{"InexactExponent", `1.5e300`, "number 1.5e300 cannot be represented exactly as an IEEE 754 double"},
+		{"InexactFraction", `9007199254740992.5`, "number 9007199254740992.5 cannot be represented exactly as an IEEE 754 double"},
+		{"LoneHighSurrogate", `"\ud800"`, `invalid JSON: lone surrogate escape \ud800`},
+		{"LoneLowSurrogate", `["x\\", "\udc00y"]`, `invalid JSON: lone surrogate escape \udc00`},
+		{"ReversedSurrogates", `"\ude00\ud83d"`, `invalid JSON: lone surrogate escape \ude00`},
+		{"HighSurrogateThenOther", `"\ud83d\u0041"`, `invalid JSON: lone surrogate escape \ud83d`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Canonicalize([]byte(tt.input))
+			require.EqualError(t, err, tt.expectedErr)
+		})
+	}
+
+	for _, malformed := range []string{``, `{"a":1`, `{"a" 1}`, `[1,]`} {
+		_, err := Canonicalize([]byte(malformed))
+		require.Error(t, err, "input %q", malformed)
+		require.Contains(t, err.Error(), "invalid JSON", "input %q", malformed)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	type record struct {
+		Name    string  `json:"name"`
+		Balance float64 `json:"balance"`
+		Email   string  `json:"email"`
+	}
+	out, err := Marshal(&record{Name: "Alice <A>", Balance: 10.50, Email: "alice@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, `{"balance":10.5,"email":"alice@example.com","name":"Alice <A>"}`, string(out))
+
+	_, err = Marshal(make(chan int))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error marshaling value")
+}
+
+func TestEqual(t *testing.T) {
+	eq, err := Equal([]byte(`{"a":1.0,"b":[1,2]}`), []byte(`{ "b":[1, 2], "a":1 }`))
+	require.NoError(t, err)
+	require.True(t, eq)
+
+	eq, err = Equal([]byte(`{"a":1}`), []byte(`{"a":2}`))
+	require.NoError(t, err)
+	require.False(t, eq)
+
+	// distinct values must never compare equal through rounding or
+	// replacement characters
+	_, err = Equal([]byte(`{"n":9007199254740993}`), []byte(`{"n":9007199254740992}`))
+	require.EqualError(t, err, "number 9007199254740993 cannot be represented exactly as an IEEE 754 double")
+	_, err = Equal([]byte(`"\ud800"`), []byte(`"\ufffd"`))
+	require.EqualError(t, err, `invalid JSON: lone surrogate escape \ud800`)
+
+	_, err = Equal([]byte(`{`), []byte(`{}`))
+	require.Error(t, err)
+	_, err = Equal([]byte(`{}`), []byte(`{`))
+	require.Error(t, err)
+}
@@ -0,0 +1,201 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package chunkhash hashes values as a chain of fixed-size chunks so that a
+// value which only ever grows at the end can be rehashed after an append by
+// processing the appended bytes and the last partial chunk, rather than the
+// whole value.
+package chunkhash
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultChunkSize is the chunk size used for values of unknown shape.
+	DefaultChunkSize = 4096
+
+	// MaxChunkSize is the largest supported chunk size. A Hasher buffers up
+	// to one chunk, so decoded states must not be able to demand more.
+	MaxChunkSize = 1 << 20
+
+	// StateSize is the length of an encoded State.
+	StateSize = 4 + 8 + sha256.Size
+
+	domain = "hyperledger-fabric/gdpr/chunkhash"
+
+	chainPrefix byte = 0
+	finalPrefix byte = 1
+)
+
+// State is the part of a hash computation that covers the complete chunks
+// of a value. Together with the bytes of the value after the last complete
+// chunk, it is enough to continue hashing the value.
+type State struct {
+	ChunkSize uint32
+	Chunks    uint64
+	Chain     [sha256.Size]byte
+}
+
+// Bytes returns the fixed-length encoding of the state.
+func (s *State) Bytes() []byte {
+	raw := make([]byte, StateSize)
+	binary.BigEndian.PutUint32(raw, s.ChunkSize)
+	binary.BigEndian.PutUint64(raw[4:], s.Chunks)
+	copy(raw[12:], s.Chain[:])
+	return raw
+}
+
+// TailOffset returns the offset in a value of the bytes that must be passed
+// to Resume along with the state.
+func (s *State) TailOffset() uint64 {
+	return s.Chunks * uint64(s.ChunkSize)
+}
+
+// StateFromBytes decodes a state encoded with Bytes.
+func StateFromBytes(raw []byte) (*State, error) {
+	if len(raw) != StateSize {
+		return nil, errors.Errorf("invalid state length %d, expected %d", len(raw), StateSize)
+	}
+	s := &State{
+		ChunkSize: binary.BigEndian.Uint32(raw),
+		Chunks:    binary.BigEndian.Uint64(raw[4:]),
+	}
+	if s.ChunkSize == 0 {
+		return nil, errors.New("invalid state: chunk size is zero")
+	}
+	if s.ChunkSize > MaxChunkSize {
+		return nil, errors.Errorf("invalid state: chunk size %d exceeds %d", s.ChunkSize, MaxChunkSize)
+	}
+	copy(s.Chain[:], raw[12:])
+	return s, nil
+}
+
+// Hasher computes the chunked hash of the bytes written to it. It
+// implements hash.Hash.
+type Hasher struct {
+	chunkSize int
+	chunks    uint64
+	chain     [sha256.Size]byte
+	tail      []byte
+}
+
+var _ hash.Hash = &Hasher{}
+
+// New returns a Hasher using the given chunk size, which must be positive
+// and at most MaxChunkSize.
+func New(chunkSize int) (*Hasher, error) {
+	if chunkSize <= 0 || chunkSize > MaxChunkSize {
+		return nil, errors.Errorf("invalid chunk size %d", chunkSize)
+	}
+	h := &Hasher{chunkSize: chunkSize, tail: make([]byte, 0, chunkSize)}
+	h.Reset()
+	return h, nil
+}
+
+// Resume returns a Hasher that continues from state, where tail holds the
+// bytes of the value after its last complete chunk. The result is the same
+// as if the whole value had been written to a new Hasher.
+func Resume(state *State, tail []byte) (*Hasher, error) {
+	h, err := New(int(state.ChunkSize))
+	if err != nil {
+		return nil, err
+	}
+	if len(tail) >= h.chunkSize {
+		return nil, errors.Errorf("tail of %d bytes is not shorter than chunk size %d", len(tail), h.chunkSize)
+	}
+	h.chunks = state.Chunks
+	h.chain = state.Chain
+	h.tail = append(h.tail, tail...)
+	return h, nil
+}
+
+// Write adds p to the hashed value. It never returns an error.
+func (h *Hasher) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		take := h.chunkSize - len(h.tail)
+		if take > len(p) {
+			take = len(p)
+		}
+		h.tail = append(h.tail, p[:take]...)
+		p = p[take:]
+		if len(h.tail) == h.chunkSize {
+			h.chain = chainHash(h.chain, h.tail)
+			h.chunks++
+			h.tail = h.tail[:0]
+		}
+	}
+	return n, nil
+}
+
+// Sum appends the hash of the value written so far to b. It does not change
+// the state of the Hasher.
+func (h *Hasher) Sum(b []byte) []byte {
+	length := make([]byte, 8)
+	binary.BigEndian.PutUint64(length, h.Length())
+
+	d := sha256.New()
+	d.Write([]byte{finalPrefix})
+	d.Write(h.chain[:])
+	d.Write(length)
+	d.Write(h.tail)
+	return d.Sum(b)
+}
+
+// State returns the state covering the complete chunks written so far.
+func (h *Hasher) State() *State {
+	return &State{ChunkSize: uint32(h.chunkSize), Chunks: h.chunks, Chain: h.chain}
+}
+
+// Length returns the number of bytes written so far, including those
+// covered by a resumed state.
+func (h *Hasher) Length() uint64 {
+	return h.chunks*uint64(h.chunkSize) + uint64(len(h.tail))
+}
+
+// Reset discards everything written to the Hasher.
+func (h *Hasher) Reset() {
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(h.chunkSize))
+	h.chain = sha256.Sum256(append([]byte(domain), size...))
+	h.chunks = 0
+	h.tail = h.tail[:0]
+}
+
+// Size returns the length of the hash returned by Sum.
+func (h *Hasher) Size() int {
+	return sha256.Size
+}
+
+// BlockSize returns the chunk size.
+func (h *Hasher) BlockSize() int {
+	return h.chunkSize
+}
+
+// Sum returns the chunked hash of value.
+func Sum(value []byte, chunkSize int) ([]byte, error) {
+	h, err := New(chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(value)
+	return h.Sum(nil), nil
+}
+
+func chainHash(chain [sha256.Size]byte, chunk []byte) [sha256.Size]byte {
+	d := sha256.New()
+	d.Write([]byte{chainPrefix})
+	d.Write(chain[:])
+	d.Write(chunk)
+	var next [sha256.Size]byte
+	d.Sum(next[:0])
+	return next
+}
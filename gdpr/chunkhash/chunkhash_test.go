@@ -0,0 +1,127 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chunkhash
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func randomValue(t *testing.T, n int) []byte {
+	value := make([]byte, n)
+	_, err := rand.Read(value)
+	require.NoError(t, err)
+	return value
+}
+
+func TestSumIndependentOfWriteSplits(t *testing.T) {
+	value := randomValue(t, 1000)
+	expected, err := Sum(value, 64)
+	require.NoError(t, err)
+
+	for _, step := range []int{1, 7, 63, 64, 65, 999} {
+		h, err := New(64)
+		require.NoError(t, err)
+		for i := 0; i < len(value); i += step {
+			end := i + step
+			if end > len(value) {
+				end = len(value)
+			}
+			h.Write(value[i:end])
+		}
+		require.Equal(t, expected, h.Sum(nil), "step %d", step)
+		require.Equal(t, uint64(1000), h.Length())
+	}
+}
+
+func TestSumDistinguishesValues(t *testing.T) {
+	sums := map[string]bool{}
+	for _, v := range [][]byte{nil, {0}, {0, 0}, bytes.Repeat([]byte{0}, 64), bytes.Repeat([]byte{0}, 65)} {
+		s, err := Sum(v, 64)
+		require.NoError(t, err)
+		require.False(t, sums[string(s)], "collision for value of %d bytes", len(v))
+		sums[string(s)] = true
+	}
+
+	// the chunk size is part of the scheme
+	value := randomValue(t, 200)
+	s64, err := Sum(value, 64)
+	require.NoError(t, err)
+	s128, err := Sum(value, 128)
+	require.NoError(t, err)
+	require.NotEqual(t, s64, s128)
+}
+
+func TestResumeAfterAppend(t *testing.T) {
+	value := randomValue(t, 1000)
+	h, err := New(64)
+	require.NoError(t, err)
+	h.Write(value)
+	state := h.State()
+	require.Equal(t, uint64(15), state.Chunks)
+	require.Equal(t, uint64(960), state.TailOffset())
+
+	decoded, err := StateFromBytes(state.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, state, decoded)
+
+	delta := randomValue(t, 300)
+	resumed, err := Resume(decoded, value[decoded.TailOffset():])
+	require.NoError(t, err)
+	require.Equal(t, h.Sum(nil), resumed.Sum(nil))
+	resumed.Write(delta)
+
+	expected, err := Sum(append(value, delta...), 64)
+	require.NoError(t, err)
+	require.Equal(t, expected, resumed.Sum(nil))
+	require.Equal(t, uint64(1300), resumed.Length())
+}
+
+func TestSumDoesNotChangeState(t *testing.T) {
+	h, err := New(16)
+	require.NoError(t, err)
+	h.Write([]byte("some bytes"))
+	first := h.Sum([]byte("prefix"))
+	require.Equal(t, []byte("prefix"), first[:6])
+	require.Equal(t, first[6:], h.Sum(nil))
+	require.Len(t, h.Sum(nil), h.Size())
+
+	h.Reset()
+	empty, err := Sum(nil, 16)
+	require.NoError(t, err)
+	require.Equal(t, empty, h.Sum(nil))
+	require.Equal(t, 16, h.BlockSize())
+}
+
+func TestErrors(t *testing.T) {
+	_, err := New(0)
+	require.EqualError(t, err, "invalid chunk size 0")
+	_, err = Sum(nil, -1)
+	require.EqualError(t, err, "invalid chunk size -1")
+	_, err = New(MaxChunkSize + 1)
+	require.EqualError(t, err, "invalid chunk size 1048577")
+	_, err = Resume(&State{ChunkSize: 0xFFFFFFFF}, nil)
+	require.EqualError(t, err, "invalid chunk size 4294967295")
+
+	h, err := New(4)
+	require.NoError(t, err)
+	_, err = Resume(h.State(), []byte("four"))
+	require.EqualError(t, err, "tail of 4 bytes is not shorter than chunk size 4")
+
+	_, err = StateFromBytes([]byte{1})
+	require.EqualError(t, err, "invalid state length 1, expected 44")
+	_, err = StateFromBytes(make([]byte, StateSize))
+	require.EqualError(t, err, "invalid state: chunk size is zero")
+	raw := make([]byte, StateSize)
+	binary.BigEndian.PutUint32(raw, 0xFFFFFFFF)
+	_, err = StateFromBytes(raw)
+	require.EqualError(t, err, "invalid state: chunk size 4294967295 exceeds 1048576")
+}
@@ -0,0 +1,174 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package commitment implements Pedersen commitments to numeric values over
+// the FP256BN group used by idemix. A commitment C = g^v * h^r hides v, and
+// commitments can be added so that the sum of committed values can be
+// checked against an aggregated opening without opening any single value.
+package commitment
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/hyperledger/fabric-amcl/amcl"
+	"github.com/hyperledger/fabric-amcl/amcl/FP256BN"
+	"github.com/hyperledger/fabric/idemix"
+	"github.com/pkg/errors"
+)
+
+// generatorHSeed is hashed onto the curve to derive H. Nobody knows the
+// discrete logarithm of H with respect to G, which is what makes the
+// commitments binding.
+const generatorHSeed = "hyperledger-fabric/gdpr/commitment/H"
+
+var (
+	// G is the generator the committed value is raised to.
+	G = idemix.GenG1
+	// H is the generator the blinding factor is raised to.
+	H = hashToG1([]byte(generatorHSeed))
+)
+
+// PointBytes is the length of an encoded commitment.
+var PointBytes = 2*idemix.FieldBytes + 1
+
+// Commitment is a Pedersen commitment to a value.
+type Commitment struct {
+	point *FP256BN.ECP
+}
+
+// Opening holds the committed value and blinding factor, both modulo the
+// group order. It is the data that takes the place of a preimage: whoever
+// holds it can open the commitment, and erasing it leaves the commitment
+// unopenable.
+type Opening struct {
+	Value    *FP256BN.BIG
+	Blinding *FP256BN.BIG
+}
+
+// NewOpening returns an opening for value with a fresh random blinding
+// factor.
+func NewOpening(value uint64, rng *amcl.RAND) *Opening {
+	return &Opening{
+		Value:    BigFromUint64(value),
+		Blinding: idemix.RandModOrder(rng),
+	}
+}
+
+// Commit returns the commitment for the opening.
+func Commit(o *Opening) *Commitment {
+	return &Commitment{point: G.Mul2(o.Value, H, o.Blinding)}
+}
+
+// Verify reports whether the opening opens the commitment.
+func Verify(c *Commitment, o *Opening) bool {
+	if c == nil || o == nil || o.Value == nil || o.Blinding == nil {
+		return false
+	}
+	return Commit(o).Equals(c)
+}
+
+// Add returns a commitment to the sum of the values committed in cs.
+func Add(cs ...*Commitment) *Commitment {
+	sum := FP256BN.NewECP()
+	for _, c := range cs {
+		sum.Add(c.point)
+	}
+	sum.Affine()
+	return &Commitment{point: sum}
+}
+
+// AddOpenings returns the opening of the commitment produced by Add for the
+// corresponding commitments.
+func AddOpenings(os ...*Opening) *Opening {
+	value, blinding := FP256BN.NewBIGint(0), FP256BN.NewBIGint(0)
+	for _, o := range os {
+		value = idemix.Modadd(value, o.Value, idemix.GroupOrder)
+		blinding = idemix.Modadd(blinding, o.Blinding, idemix.GroupOrder)
+	}
+	return &Opening{Value: value, Blinding: blinding}
+}
+
+// VerifySum reports whether the values committed in cs add up to total,
+// given the sum of their blinding factors. It reveals nothing about the
+// individual values.
+func VerifySum(total uint64, blindingSum *FP256BN.BIG, cs ...*Commitment) bool {
+	return Verify(Add(cs...), &Opening{Value: BigFromUint64(total), Blinding: blindingSum})
+}
+
+// Equals reports whether two commitments are equal.
+func (c *Commitment) Equals(other *Commitment) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	return c.point.Equals(other.point)
+}
+
+// Point returns a copy of the underlying group element.
+func (c *Commitment) Point() *FP256BN.ECP {
+	p := FP256BN.NewECP()
+	p.Copy(c.point)
+	return p
+}
+
+// NewCommitment wraps a group element as a commitment.
+func NewCommitment(p *FP256BN.ECP) *Commitment {
+	c := FP256BN.NewECP()
+	c.Copy(p)
+	c.Affine()
+	return &Commitment{point: c}
+}
+
+// Bytes returns the uncompressed encoding of the commitment.
+func (c *Commitment) Bytes() []byte {
+	return idemix.EcpToBytes(c.point)
+}
+
+// FromBytes decodes a commitment encoded with Bytes.
+func FromBytes(raw []byte) (*Commitment, error) {
+	if len(raw) != PointBytes {
+		return nil, errors.Errorf("invalid commitment length %d, expected %d", len(raw), PointBytes)
+	}
+	p := FP256BN.ECP_fromBytes(raw)
+	if p.Is_infinity() {
+		return nil, errors.New("commitment is not a valid curve point")
+	}
+	return &Commitment{point: p}, nil
+}
+
+// Bytes returns the encoding of the opening: the value followed by the
+// blinding factor, each FieldBytes long.
+func (o *Opening) Bytes() []byte {
+	raw := make([]byte, 2*idemix.FieldBytes)
+	o.Value.ToBytes(raw[:idemix.FieldBytes])
+	o.Blinding.ToBytes(raw[idemix.FieldBytes:])
+	return raw
+}
+
+// OpeningFromBytes decodes an opening encoded with Bytes.
+func OpeningFromBytes(raw []byte) (*Opening, error) {
+	if len(raw) != 2*idemix.FieldBytes {
+		return nil, errors.Errorf("invalid opening length %d, expected %d", len(raw), 2*idemix.FieldBytes)
+	}
+	value := FP256BN.FromBytes(raw[:idemix.FieldBytes])
+	blinding := FP256BN.FromBytes(raw[idemix.FieldBytes:])
+	if FP256BN.Comp(value, idemix.GroupOrder) >= 0 || FP256BN.Comp(blinding, idemix.GroupOrder) >= 0 {
+		return nil, errors.New("opening is not reduced modulo the group order")
+	}
+	return &Opening{Value: value, Blinding: blinding}, nil
+}
+
+// BigFromUint64 converts v to a BIG.
+func BigFromUint64(v uint64) *FP256BN.BIG {
+	raw := make([]byte, idemix.FieldBytes)
+	binary.BigEndian.PutUint64(raw[idemix.FieldBytes-8:], v)
+	return FP256BN.FromBytes(raw)
+}
+
+func hashToG1(seed []byte) *FP256BN.ECP {
+	digest := sha256.Sum256(seed)
+	return FP256BN.ECP_mapit(digest[:])
+}
@@ -0,0 +1,118 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package commitment
+
+import (
+	"math"
+	"testing"
+
+	"github.com/hyperledger/fabric-amcl/amcl/FP256BN"
+	"github.com/hyperledger/fabric/idemix"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerators(t *testing.T) {
+	require.False(t, H.Is_infinity())
+	require.False(t, H.Equals(G))
+	require.True(t, H.Equals(hashToG1([]byte(generatorHSeed))), "H must be deterministic")
+}
+
+func TestCommitVerify(t *testing.T) {
+	rng, err := idemix.GetRand()
+	require.NoError(t, err)
+
+	o := NewOpening(52000, rng)
+	c := Commit(o)
+	require.True(t, Verify(c, o))
+
+	require.False(t, Verify(c, &Opening{Value: BigFromUint64(52001), Blinding: o.Blinding}))
+	require.False(t, Verify(c, &Opening{Value: o.Value, Blinding: idemix.RandModOrder(rng)}))
+	require.False(t, Verify(c, nil))
+	require.False(t, Verify(nil, o))
+	require.False(t, Verify(c, &Opening{Value: o.Value}))
+
+	// the same value committed twice yields unrelated commitments
+	require.False(t, c.Equals(Commit(NewOpening(52000, rng))))
+}
+
+func TestHomomorphicSum(t *testing.T) {
+	rng, err := idemix.GetRand()
+	require.NoError(t, err)
+
+	salaries := []uint64{41000, 52000, 67500}
+	var cs []*Commitment
+	var os []*Opening
+	for _, s := range salaries {
+		o := NewOpening(s, rng)
+		os = append(os, o)
+		cs = append(cs, Commit(o))
+	}
+
+	sum := AddOpenings(os...)
+	require.True(t, Verify(Add(cs...), sum))
+	require.True(t, VerifySum(160500, sum.Blinding, cs...))
+	require.False(t, VerifySum(160501, sum.Blinding, cs...))
+	require.False(t, VerifySum(160500, sum.Blinding, cs[:2]...))
+}
+
+func TestSumWrapsModuloGroupOrder(t *testing.T) {
+	rng, err := idemix.GetRand()
+	require.NoError(t, err)
+
+	o1, o2 := NewOpening(math.MaxUint64, rng), NewOpening(1, rng)
+	sum := AddOpenings(o1, o2)
+
+	expected := BigFromUint64(math.MaxUint64)
+	expected = idemix.Modadd(expected, FP256BN.NewBIGint(1), idemix.GroupOrder)
+	require.Zero(t, FP256BN.Comp(expected, sum.Value))
+	require.True(t, Verify(Add(Commit(o1), Commit(o2)), sum))
+}
+
+func TestSerialization(t *testing.T) {
+	rng, err := idemix.GetRand()
+	require.NoError(t, err)
+
+	o := NewOpening(18, rng)
+	c := Commit(o)
+
+	raw := c.Bytes()
+	require.Len(t, raw, PointBytes)
+	decoded, err := FromBytes(raw)
+	require.NoError(t, err)
+	require.True(t, c.Equals(decoded))
+
+	decodedOpening, err := OpeningFromBytes(o.Bytes())
+	require.NoError(t, err)
+	require.True(t, Verify(decoded, decodedOpening))
+
+	require.True(t, c.Equals(NewCommitment(c.Point())))
+}
+
+func TestSerializationErrors(t *testing.T) {
+	_, err := FromBytes([]byte{4, 1, 2})
+	require.EqualError(t, err, "invalid commitment length 3, expected 65")
+
+	offCurve := make([]byte, PointBytes)
+	offCurve[0] = 0x04
+	offCurve[PointBytes-1] = 1
+	_, err = FromBytes(offCurve)
+	require.EqualError(t, err, "commitment is not a valid curve point")
+
+	_, err = OpeningFromBytes([]byte{1})
+	require.EqualError(t, err, "invalid opening length 1, expected 64")
+
+	unreduced := make([]byte, 2*idemix.FieldBytes)
+	idemix.GroupOrder.ToBytes(unreduced[:idemix.FieldBytes])
+	_, err = OpeningFromBytes(unreduced)
+	require.EqualError(t, err, "opening is not reduced modulo the group order")
+}
+
+func TestCommitmentEquals(t *testing.T) {
+	var nilCommitment *Commitment
+	require.True(t, nilCommitment.Equals(nil))
+	require.False(t, nilCommitment.Equals(&Commitment{point: G}))
+}
@@ -0,0 +1,330 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package commitment
+
+import (
+	"github.com/hyperledger/fabric-amcl/amcl"
+	"github.com/hyperledger/fabric-amcl/amcl/FP256BN"
+	"github.com/hyperledger/fabric/idemix"
+	"github.com/pkg/errors"
+)
+
+// MaxRangeBits is the largest supported range, [0, 2^MaxRangeBits).
+const MaxRangeBits = 64
+
+const rangeProofLabel = "hyperledger-fabric/gdpr/commitment/range"
+
+// RangeProof is a bit-decomposition proof that a commitment opens to a
+// value in [0, 2^n), where n is the number of bit proofs. The value is
+// decomposed into bits, each bit is committed separately, and every bit
+// commitment carries a non-interactive OR proof that it opens to either 0
+// or 1. The verifier checks that the bit commitments, weighted by powers of
+// two, add up to the original commitment.
+//
+// This is not a Bulletproof: the proof grows linearly with the range, by
+// 193 bytes per bit, rather than logarithmically. See RangeProofSize for
+// the budget a transaction must allow for, and prefer the narrowest range
+// a business rule permits.
+type RangeProof struct {
+	Bits []*BitProof
+}
+
+// BitProof proves that Commitment opens to 0 or 1 without revealing which.
+type BitProof struct {
+	Commitment *Commitment
+	C0, C1     *FP256BN.BIG
+	Z0, Z1     *FP256BN.BIG
+}
+
+// ProveRange proves that the value in o lies in [0, 2^bits).
+func ProveRange(o *Opening, bits int, rng *amcl.RAND) (*RangeProof, error) {
+	if bits < 1 || bits > MaxRangeBits {
+		return nil, errors.Errorf("range of %d bits is not supported, must be between 1 and %d", bits, MaxRangeBits)
+	}
+	value := make([]byte, idemix.FieldBytes)
+	o.Value.ToBytes(value)
+	for i := bits; i < 8*len(value); i++ {
+		if bitAt(value, i) != 0 {
+			return nil, errors.Errorf("value does not fit in %d bits", bits)
+		}
+	}
+
+	c := Commit(o)
+	q := idemix.GroupOrder
+
+	// Pick random blinding factors for all bits but the last, then solve for
+	// the last one so that sum(2^i * r_i) = r.
+	blindings := make([]*FP256BN.BIG, bits)
+	acc := FP256BN.NewBIGint(0)
+	for i := 0; i < bits-1; i++ {
+		blindings[i] = idemix.RandModOrder(rng)
+		acc = idemix.Modadd(acc, FP256BN.Modmul(pow2(i), blindings[i], q), q)
+	}
+	inv := pow2(bits - 1)
+	inv.Invmodp(q)
+	blindings[bits-1] = FP256BN.Modmul(idemix.Modsub(o.Blinding, acc, q), inv, q)
+
+	proof := &RangeProof{Bits: make([]*BitProof, bits)}
+	for i := 0; i < bits; i++ {
+		proof.Bits[i] = proveBit(c, i, bitAt(value, i), blindings[i], rng)
+	}
+	return proof, nil
+}
+
+// VerifyRange verifies that c opens to a value in [0, 2^n), where n is the
+// number of bits covered by the proof.
+func VerifyRange(c *Commitment, proof *RangeProof) error {
+	if c == nil || proof == nil {
+		return errors.New("range proof invalid: received nil input")
+	}
+	bits := len(proof.Bits)
+	if bits < 1 || bits > MaxRangeBits {
+		return errors.Errorf("range proof invalid: %d bits is not supported", bits)
+	}
+
+	sum := FP256BN.NewECP()
+	for i, bp := range proof.Bits {
+		if err := verifyBit(c, i, bp); err != nil {
+			return errors.WithMessagef(err, "range proof invalid at bit %d", i)
+		}
+		sum.Add(bp.Commitment.point.Mul(pow2(i)))
+	}
+	if !sum.Equals(c.point) {
+		return errors.New("range proof invalid: bit commitments do not add up to the commitment")
+	}
+	return nil
+}
+
+// RangeProofSize returns the encoded size in bytes of a range proof over
+// the given number of bits: 3089 bytes for 16 bits and 12353 bytes for 64
+// bits. A LessThanProof holds two range proofs of the same width, so one
+// over 63 bits takes 24320 bytes.
+func RangeProofSize(bits int) int {
+	return 1 + bits*bitProofBytes()
+}
+
+// Bytes returns the encoding of the proof: the number of bits followed, for
+// every bit, by the bit commitment and the four proof scalars.
+func (p *RangeProof) Bytes() []byte {
+	raw := make([]byte, 1, RangeProofSize(len(p.Bits)))
+	raw[0] = byte(len(p.Bits))
+	for _, bp := range p.Bits {
+		raw = append(raw, bp.Commitment.Bytes()...)
+		for _, s := range []*FP256BN.BIG{bp.C0, bp.C1, bp.Z0, bp.Z1} {
+			raw = append(raw, idemix.BigToBytes(s)...)
+		}
+	}
+	return raw
+}
+
+// RangeProofFromBytes decodes a proof encoded with Bytes. It returns the
+// number of bytes consumed so that proofs can be concatenated.
+func RangeProofFromBytes(raw []byte) (*RangeProof, int, error) {
+	if len(raw) == 0 {
+		return nil, 0, errors.New("empty range proof")
+	}
+	bits := int(raw[0])
+	if bits < 1 || bits > MaxRangeBits {
+		return nil, 0, errors.Errorf("range proof of %d bits is not supported", bits)
+	}
+	size := RangeProofSize(bits)
+	if len(raw) < size {
+		return nil, 0, errors.Errorf("range proof of %d bits needs %d bytes, got %d", bits, size, len(raw))
+	}
+
+	proof := &RangeProof{Bits: make([]*BitProof, bits)}
+	offset := 1
+	for i := range proof.Bits {
+		c, err := FromBytes(raw[offset : offset+PointBytes])
+		if err != nil {
+			return nil, 0, errors.WithMessagef(err, "bad commitment for bit %d", i)
+		}
+		offset += PointBytes
+		scalars := make([]*FP256BN.BIG, 4)
+		for j := range scalars {
+			scalars[j] = FP256BN.FromBytes(raw[offset : offset+idemix.FieldBytes])
+			if FP256BN.Comp(scalars[j], idemix.GroupOrder) >= 0 {
+				return nil, 0, errors.Errorf("bad proof for bit %d: scalar is not reduced modulo the group order", i)
+			}
+			offset += idemix.FieldBytes
+		}
+		proof.Bits[i] = &BitProof{Commitment: c, C0: scalars[0], C1: scalars[1], Z0: scalars[2], Z1: scalars[3]}
+	}
+	return proof, size, nil
+}
+
+// LessThanProof proves that a commitment opens to a value v with
+// 0 <= v < limit. It consists of a range proof for v itself and one for
+// limit-1-v. Both are needed: since values are reduced modulo the group
+// order, a proof for the gap alone would also accept "negative" values.
+type LessThanProof struct {
+	Value *RangeProof
+	Gap   *RangeProof
+}
+
+// ProveLessThan proves that the value in o is strictly less than limit,
+// using ranges of the given number of bits.
+func ProveLessThan(o *Opening, limit uint64, bits int, rng *amcl.RAND) (*LessThanProof, error) {
+	if limit == 0 {
+		return nil, errors.New("limit must be greater than zero")
+	}
+	if bits >= MaxRangeBits {
+		return nil, errors.Errorf("value and gap ranges must have equal width below %d bits, got %d", MaxRangeBits, bits)
+	}
+	q := idemix.GroupOrder
+	if FP256BN.Comp(o.Value, BigFromUint64(limit)) >= 0 {
+		return nil, errors.Errorf("value is not less than %d", limit)
+	}
+
+	valueProof, err := ProveRange(o, bits, rng)
+	if err != nil {
+		return nil, err
+	}
+	gap := &Opening{
+		Value:    idemix.Modsub(BigFromUint64(limit-1), o.Value, q),
+		Blinding: FP256BN.Modneg(o.Blinding, q),
+	}
+	gapProof, err := ProveRange(gap, bits, rng)
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot prove gap to limit")
+	}
+	return &LessThanProof{Value: valueProof, Gap: gapProof}, nil
+}
+
+// Bytes returns the encoding of the proof, the value proof followed by the
+// gap proof.
+func (p *LessThanProof) Bytes() []byte {
+	return append(p.Value.Bytes(), p.Gap.Bytes()...)
+}
+
+// LessThanProofFromBytes decodes a proof encoded with Bytes.
+func LessThanProofFromBytes(raw []byte) (*LessThanProof, error) {
+	value, n, err := RangeProofFromBytes(raw)
+	if err != nil {
+		return nil, errors.WithMessage(err, "value")
+	}
+	gap, m, err := RangeProofFromBytes(raw[n:])
+	if err != nil {
+		return nil, errors.WithMessage(err, "gap")
+	}
+	if n+m != len(raw) {
+		return nil, errors.Errorf("%d trailing bytes after less-than proof", len(raw)-n-m)
+	}
+	return &LessThanProof{Value: value, Gap: gap}, nil
+}
+
+// VerifyLessThan verifies that c opens to a value strictly less than limit.
+func VerifyLessThan(c *Commitment, limit uint64, proof *LessThanProof) error {
+	if c == nil || proof == nil {
+		return errors.New("less-than proof invalid: received nil input")
+	}
+	if limit == 0 {
+		return errors.New("limit must be greater than zero")
+	}
+	if proof.Value == nil || proof.Gap == nil {
+		return errors.New("less-than proof invalid: incomplete proof")
+	}
+	if bits := len(proof.Value.Bits); bits >= MaxRangeBits || len(proof.Gap.Bits) != bits {
+		return errors.New("less-than proof invalid: value and gap ranges must have equal width below 64 bits")
+	}
+	if err := VerifyRange(c, proof.Value); err != nil {
+		return errors.WithMessage(err, "value")
+	}
+
+	// g^(limit-1) / C commits to limit-1-v under blinding -r
+	gap := G.Mul(BigFromUint64(limit - 1))
+	gap.Sub(c.point)
+	gap.Affine()
+	if err := VerifyRange(&Commitment{point: gap}, proof.Gap); err != nil {
+		return errors.WithMessage(err, "gap")
+	}
+	return nil
+}
+
+// proveBit produces a Cramer-Damgard-Schoenmakers OR proof that the bit
+// commitment h^r * g^bit opens to 0 or 1. The branch for the actual bit is
+// proven honestly and the other one is simulated.
+func proveBit(parent *Commitment, index, bit int, r *FP256BN.BIG, rng *amcl.RAND) *BitProof {
+	q := idemix.GroupOrder
+	cb := Commit(&Opening{Value: FP256BN.NewBIGint(bit), Blinding: r})
+	y := bitStatements(cb)
+
+	honest, fake := bit, 1-bit
+	c := make([]*FP256BN.BIG, 2)
+	z := make([]*FP256BN.BIG, 2)
+	a := make([]*FP256BN.ECP, 2)
+
+	// simulated branch: A = h^z / Y^c for random c, z
+	c[fake] = idemix.RandModOrder(rng)
+	z[fake] = idemix.RandModOrder(rng)
+	a[fake] = H.Mul(z[fake])
+	a[fake].Sub(y[fake].Mul(c[fake]))
+
+	// honest branch: A = h^k
+	k := idemix.RandModOrder(rng)
+	a[honest] = H.Mul(k)
+
+	challenge := bitChallenge(parent, index, cb, a[0], a[1])
+	c[honest] = idemix.Modsub(challenge, c[fake], q)
+	z[honest] = idemix.Modadd(k, FP256BN.Modmul(c[honest], r, q), q)
+
+	return &BitProof{Commitment: cb, C0: c[0], C1: c[1], Z0: z[0], Z1: z[1]}
+}
+
+func verifyBit(parent *Commitment, index int, bp *BitProof) error {
+	if bp == nil || bp.Commitment == nil || bp.C0 == nil || bp.C1 == nil || bp.Z0 == nil || bp.Z1 == nil {
+		return errors.New("incomplete bit proof")
+	}
+	y := bitStatements(bp.Commitment)
+	c := []*FP256BN.BIG{bp.C0, bp.C1}
+	z := []*FP256BN.BIG{bp.Z0, bp.Z1}
+
+	a := make([]*FP256BN.ECP, 2)
+	for i := range a {
+		a[i] = H.Mul(z[i])
+		a[i].Sub(y[i].Mul(c[i]))
+	}
+
+	challenge := bitChallenge(parent, index, bp.Commitment, a[0], a[1])
+	if FP256BN.Comp(idemix.Modadd(bp.C0, bp.C1, idemix.GroupOrder), challenge) != 0 {
+		return errors.New("bit proof challenge mismatch")
+	}
+	return nil
+}
+
+// bitStatements returns the two points of which the prover claims to know
+// the discrete log base h: C (bit is 0) and C/g (bit is 1).
+func bitStatements(cb *Commitment) []*FP256BN.ECP {
+	y0 := cb.Point()
+	y1 := cb.Point()
+	y1.Sub(G)
+	return []*FP256BN.ECP{y0, y1}
+}
+
+func bitChallenge(parent *Commitment, index int, cb *Commitment, a0, a1 *FP256BN.ECP) *FP256BN.BIG {
+	data := make([]byte, 0, len(rangeProofLabel)+1+4*PointBytes)
+	data = append(data, rangeProofLabel...)
+	data = append(data, byte(index))
+	data = append(data, parent.Bytes()...)
+	data = append(data, cb.Bytes()...)
+	data = append(data, idemix.EcpToBytes(a0)...)
+	data = append(data, idemix.EcpToBytes(a1)...)
+	return idemix.HashModOrder(data)
+}
+
+func bitProofBytes() int {
+	return PointBytes + 4*idemix.FieldBytes
+}
+
+// bitAt returns bit i of the big-endian encoded value.
+func bitAt(value []byte, i int) int {
+	return int(value[len(value)-1-i/8]>>(uint(i)%8)) & 1
+}
+
+func pow2(i int) *FP256BN.BIG {
+	return BigFromUint64(1 << uint(i))
+}
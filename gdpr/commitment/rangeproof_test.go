@@ -0,0 +1,171 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package commitment
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-amcl/amcl/FP256BN"
+	"github.com/hyperledger/fabric/idemix"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeProof(t *testing.T) {
+	rng, err := idemix.GetRand()
+	require.NoError(t, err)
+
+	for _, v := range []uint64{0, 1, 2, 170, 255} {
+		o := NewOpening(v, rng)
+		c := Commit(o)
+		proof, err := ProveRange(o, 8, rng)
+		require.NoError(t, err, "value %d", v)
+		require.Len(t, proof.Bits, 8)
+		require.NoError(t, VerifyRange(c, proof), "value %d", v)
+	}
+}
+
+func TestRangeProofRejections(t *testing.T) {
+	rng, err := idemix.GetRand()
+	require.NoError(t, err)
+
+	o := NewOpening(256, rng)
+	_, err = ProveRange(o, 8, rng)
+	require.EqualError(t, err, "value does not fit in 8 bits")
+	_, err = ProveRange(o, 0, rng)
+	require.EqualError(t, err, "range of 0 bits is not supported, must be between 1 and 64")
+	_, err = ProveRange(o, 65, rng)
+	require.EqualError(t, err, "range of 65 bits is not supported, must be between 1 and 64")
+
+	// a proof for one commitment does not verify against another
+	o1, o2 := NewOpening(3, rng), NewOpening(3, rng)
+	proof, err := ProveRange(o1, 4, rng)
+	require.NoError(t, err)
+	err = VerifyRange(Commit(o2), proof)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "range proof invalid at bit 0: bit proof challenge mismatch")
+
+	// tampering with a bit proof breaks it
+	proof, err = ProveRange(o1, 4, rng)
+	require.NoError(t, err)
+	proof.Bits[2].Z0 = idemix.Modadd(proof.Bits[2].Z0, FP256BN.NewBIGint(1), idemix.GroupOrder)
+	err = VerifyRange(Commit(o1), proof)
+	require.EqualError(t, err, "range proof invalid at bit 2: bit proof challenge mismatch")
+
+	// dropping the top bit breaks the reconstruction
+	proof, err = ProveRange(NewOpening(9, rng), 4, rng)
+	require.NoError(t, err)
+	require.Error(t, VerifyRange(Commit(o1), &RangeProof{Bits: proof.Bits[:3]}))
+
+	require.EqualError(t, VerifyRange(nil, proof), "range proof invalid: received nil input")
+	require.EqualError(t, VerifyRange(Commit(o1), &RangeProof{}), "range proof invalid: 0 bits is not supported")
+	require.EqualError(t, VerifyRange(Commit(o1), &RangeProof{Bits: []*BitProof{{}}}), "range proof invalid at bit 0: incomplete bit proof")
+}
+
+func TestRangeProofSerialization(t *testing.T) {
+	rng, err := idemix.GetRand()
+	require.NoError(t, err)
+
+	o := NewOpening(1234, rng)
+	proof, err := ProveRange(o, 16, rng)
+	require.NoError(t, err)
+
+	raw := proof.Bytes()
+	require.Len(t, raw, RangeProofSize(16))
+	require.Equal(t, 3089, RangeProofSize(16))
+	require.Equal(t, 12353, RangeProofSize(64))
+	decoded, n, err := RangeProofFromBytes(raw)
+	require.NoError(t, err)
+	require.Equal(t, len(raw), n)
+	require.NoError(t, VerifyRange(Commit(o), decoded))
+
+	_, _, err = RangeProofFromBytes(nil)
+	require.EqualError(t, err, "empty range proof")
+	_, _, err = RangeProofFromBytes([]byte{0})
+	require.EqualError(t, err, "range proof of 0 bits is not supported")
+	_, _, err = RangeProofFromBytes(raw[:100])
+	require.EqualError(t, err, "range proof of 16 bits needs 3089 bytes, got 100")
+
+	// scalars must be reduced so that every proof has a single encoding
+	unreduced := append([]byte{}, raw...)
+	idemix.GroupOrder.ToBytes(unreduced[1+PointBytes+idemix.FieldBytes : 1+PointBytes+2*idemix.FieldBytes])
+	_, _, err = RangeProofFromBytes(unreduced)
+	require.EqualError(t, err, "bad proof for bit 0: scalar is not reduced modulo the group order")
+}
+
+func TestLessThanProof(t *testing.T) {
+	rng, err := idemix.GetRand()
+	require.NoError(t, err)
+
+	o := NewOpening(9999, rng)
+	c := Commit(o)
+
+	proof, err := ProveLessThan(o, 10000, 32, rng)
+	require.NoError(t, err)
+	require.NoError(t, VerifyLessThan(c, 10000, proof))
+
+	require.Len(t, proof.Bytes(), 2*RangeProofSize(32))
+	decoded, err := LessThanProofFromBytes(proof.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, VerifyLessThan(c, 10000, decoded))
+
+	// the proof does not carry over to a lower limit
+	require.Error(t, VerifyLessThan(c, 9999, proof))
+
+	_, err = ProveLessThan(o, 9999, 32, rng)
+	require.EqualError(t, err, "value is not less than 9999")
+	_, err = ProveLessThan(o, 0, 32, rng)
+	require.EqualError(t, err, "limit must be greater than zero")
+	_, err = ProveLessThan(o, 10000, MaxRangeBits, rng)
+	require.EqualError(t, err, "value and gap ranges must have equal width below 64 bits, got 64")
+}
+
+func TestLessThanRejectsWrappedValues(t *testing.T) {
+	rng, err := idemix.GetRand()
+	require.NoError(t, err)
+
+	// "-1" modulo the group order: its gap to the limit is small and in
+	// range, but the value itself is not.
+	minusOne := &Opening{
+		Value:    FP256BN.Modneg(FP256BN.NewBIGint(1), idemix.GroupOrder),
+		Blinding: idemix.RandModOrder(rng),
+	}
+	gap := &Opening{
+		Value:    BigFromUint64(10),
+		Blinding: FP256BN.Modneg(minusOne.Blinding, idemix.GroupOrder),
+	}
+	gapProof, err := ProveRange(gap, 32, rng)
+	require.NoError(t, err)
+	fakeValueProof, err := ProveRange(NewOpening(5, rng), 32, rng)
+	require.NoError(t, err)
+
+	err = VerifyLessThan(Commit(minusOne), 10, &LessThanProof{Value: fakeValueProof, Gap: gapProof})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "value: range proof invalid")
+}
+
+func TestLessThanProofMalformed(t *testing.T) {
+	rng, err := idemix.GetRand()
+	require.NoError(t, err)
+	o := NewOpening(1, rng)
+	c := Commit(o)
+
+	p8, err := ProveRange(o, 8, rng)
+	require.NoError(t, err)
+	p64, err := ProveRange(o, 64, rng)
+	require.NoError(t, err)
+
+	require.EqualError(t, VerifyLessThan(nil, 2, &LessThanProof{}), "less-than proof invalid: received nil input")
+	require.EqualError(t, VerifyLessThan(c, 0, &LessThanProof{}), "limit must be greater than zero")
+	require.EqualError(t, VerifyLessThan(c, 2, &LessThanProof{Value: p8}), "less-than proof invalid: incomplete proof")
+	require.EqualError(t, VerifyLessThan(c, 2, &LessThanProof{Value: p8, Gap: p64}), "less-than proof invalid: value and gap ranges must have equal width below 64 bits")
+	require.EqualError(t, VerifyLessThan(c, 2, &LessThanProof{Value: p64, Gap: p64}), "less-than proof invalid: value and gap ranges must have equal width below 64 bits")
+
+	_, err = LessThanProofFromBytes(append(p8.Bytes(), p8.Bytes()[0]))
+	require.EqualError(t, err, "gap: range proof of 8 bits needs 1545 bytes, got 1")
+	_, err = LessThanProofFromBytes(append(append(p8.Bytes(), p8.Bytes()...), 0))
+	require.EqualError(t, err, "1 trailing bytes after less-than proof")
+}
@@ -0,0 +1,210 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package envelope seals values under a channel-scoped symmetric key while
+// binding each envelope to a keyed hash of its plaintext. The plaintext hash
+// is what gets committed on chain, so peers that do not hold the key can
+// validate writes without ever seeing the value. Since the hash is keyed,
+// those peers also cannot confirm guesses of low-entropy values against it,
+// and once every copy of the key is destroyed the hash left on chain no
+// longer links to the value at all.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"fmt"
+
+	"github.com/hyperledger/fabric/gdpr/internal/errwrap"
+	"github.com/pkg/errors"
+)
+
+const (
+	// KeySize is the size in bytes of the AES-256 keys used to seal envelopes.
+	KeySize = 32
+
+	// plaintextHashLabel separates the key used for plaintext hashes from the
+	// sealing key it is derived from.
+	plaintextHashLabel = "hyperledger-fabric/gdpr/envelope/plaintext-hash"
+)
+
+var (
+	// ErrDecryptionFailed is wrapped by Open errors for envelopes that do
+	// not authenticate under the key they name, either because the key is
+	// the wrong one or because the envelope was tampered with.
+	ErrDecryptionFailed = errors.New("message authentication failed")
+
+	// ErrHashMismatch is wrapped by Open errors for envelopes whose
+	// plaintext does not match the recorded hash.
+	ErrHashMismatch = errors.New("plaintext does not match envelope hash")
+)
+
+// KeyNotFoundError is returned by a KeyStore that has no key for the
+// requested ID. Open adds context to it, and callers detect it with
+// errors.As or with errors.Cause, as with the sentinel errors above.
+type KeyNotFoundError struct {
+	KeyID string
+}
+
+func (e *KeyNotFoundError) Error() string {
+	return fmt.Sprintf("key %s not found", e.KeyID)
+}
+
+// Key is a channel-scoped symmetric key. The ID is recorded in every
+// envelope sealed with the key so that the matching key can be located when
+// the envelope is opened.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// NewKey generates a new random key with the given identifier.
+func NewKey(id string) (*Key, error) {
+	if id == "" {
+		return nil, errors.New("key ID must not be empty")
+	}
+	secret := make([]byte, KeySize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, errors.Wrap(err, "error generating key")
+	}
+	return &Key{ID: id, Secret: secret}, nil
+}
+
+// KeyStore resolves keys by ID. Peers outside the set authorized for a key
+// simply have no entry for it.
+type KeyStore interface {
+	Key(id string) (*Key, error)
+}
+
+// MapKeyStore is a KeyStore backed by a map from key ID to key.
+type MapKeyStore map[string]*Key
+
+// Key implements KeyStore.
+func (m MapKeyStore) Key(id string) (*Key, error) {
+	k, ok := m[id]
+	if !ok {
+		return nil, &KeyNotFoundError{KeyID: id}
+	}
+	return k, nil
+}
+
+// Envelope carries a sealed value together with the hash of its plaintext.
+type Envelope struct {
+	KeyID         string
+	Nonce         []byte
+	Ciphertext    []byte
+	PlaintextHash []byte
+}
+
+// PlaintextHash returns the hash that is committed for plaintext sealed
+// under key. It is an HMAC-SHA256 under a key derived from the sealing key,
+// so the same plaintext has the same hash under a given key, and computing
+// or checking the hash requires that key.
+func PlaintextHash(key *Key, plaintext []byte) []byte {
+	derive := hmac.New(sha256.New, key.Secret)
+	derive.Write([]byte(plaintextHashLabel))
+	mac := hmac.New(sha256.New, derive.Sum(nil))
+	mac.Write(plaintext)
+	return mac.Sum(nil)
+}
+
+// Seal encrypts plaintext under key. The key ID and plaintext hash are
+// authenticated as additional data, so neither can be swapped without
+// Open failing.
+func Seal(key *Key, plaintext []byte) (*Envelope, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "error generating nonce")
+	}
+
+	env := &Envelope{
+		KeyID:         key.ID,
+		Nonce:         nonce,
+		PlaintextHash: PlaintextHash(key, plaintext),
+	}
+	env.Ciphertext = aead.Seal(nil, nonce, plaintext, env.additionalData())
+	return env, nil
+}
+
+// Open decrypts the envelope with the key it names and verifies that the
+// plaintext matches the recorded hash.
+func Open(ks KeyStore, env *Envelope) ([]byte, error) {
+	key, err := ks.Key(env.KeyID)
+	if err != nil {
+		return nil, errwrap.WithMessage(err, "cannot open envelope")
+	}
+	if key.ID != env.KeyID {
+		return nil, errors.Errorf("key store returned key %s for key ID %s", key.ID, env.KeyID)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, errors.Errorf("invalid nonce length %d", len(env.Nonce))
+	}
+
+	plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, env.additionalData())
+	if err != nil {
+		return nil, errwrap.WithMessagef(ErrDecryptionFailed, "error decrypting envelope sealed with key %s", env.KeyID)
+	}
+	if !hmac.Equal(PlaintextHash(key, plaintext), env.PlaintextHash) {
+		return nil, errwrap.WithMessagef(ErrHashMismatch, "error verifying envelope sealed with key %s", env.KeyID)
+	}
+	return plaintext, nil
+}
+
+// Bytes returns the ASN.1 encoding of the envelope.
+func (e *Envelope) Bytes() ([]byte, error) {
+	raw, err := asn1.Marshal(*e)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling envelope")
+	}
+	return raw, nil
+}
+
+// Unmarshal decodes an envelope previously encoded with Bytes.
+func Unmarshal(raw []byte) (*Envelope, error) {
+	env := &Envelope{}
+	rest, err := asn1.Unmarshal(raw, env)
+	if err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling envelope")
+	}
+	if len(rest) != 0 {
+		return nil, errors.Errorf("%d trailing bytes after envelope", len(rest))
+	}
+	return env, nil
+}
+
+func (e *Envelope) additionalData() []byte {
+	ad := make([]byte, 0, len(e.KeyID)+1+len(e.PlaintextHash))
+	ad = append(ad, e.KeyID...)
+	ad = append(ad, 0)
+	return append(ad, e.PlaintextHash...)
+}
+
+func newAEAD(key *Key) (cipher.AEAD, error) {
+	if len(key.Secret) != KeySize {
+		return nil, errors.Errorf("invalid key size %d for key %s, expected %d", len(key.Secret), key.ID, KeySize)
+	}
+	block, err := aes.NewCipher(key.Secret)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating GCM")
+	}
+	return aead, nil
+}
@@ -0,0 +1,166 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package envelope
+
+import (
+	"crypto/sha256"
+	stderrors "errors"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealOpen(t *testing.T) {
+	key, err := NewKey("mychannel/key1")
+	require.NoError(t, err)
+	ks := MapKeyStore{key.ID: key}
+
+	env, err := Seal(key, []byte("alice@example.com"))
+	require.NoError(t, err)
+	require.Equal(t, "mychannel/key1", env.KeyID)
+	require.Equal(t, PlaintextHash(key, []byte("alice@example.com")), env.PlaintextHash)
+	require.NotContains(t, string(env.Ciphertext), "alice")
+
+	raw, err := env.Bytes()
+	require.NoError(t, err)
+	decoded, err := Unmarshal(raw)
+	require.NoError(t, err)
+	require.Equal(t, env, decoded)
+
+	plaintext, err := Open(ks, decoded)
+	require.NoError(t, err)
+	require.Equal(t, []byte("alice@example.com"), plaintext)
+}
+
+func TestSealEmptyValue(t *testing.T) {
+	key, err := NewKey("k")
+	require.NoError(t, err)
+
+	env, err := Seal(key, nil)
+	require.NoError(t, err)
+	raw, err := env.Bytes()
+	require.NoError(t, err)
+	decoded, err := Unmarshal(raw)
+	require.NoError(t, err)
+
+	plaintext, err := Open(MapKeyStore{"k": key}, decoded)
+	require.NoError(t, err)
+	require.Empty(t, plaintext)
+}
+
+func TestPlaintextHashIsKeyed(t *testing.T) {
+	key, err := NewKey("k")
+	require.NoError(t, err)
+	other, err := NewKey("k")
+	require.NoError(t, err)
+
+	h := PlaintextHash(key, []byte("1970-01-01"))
+	require.Len(t, h, sha256.Size)
+	require.Equal(t, h, PlaintextHash(key, []byte("1970-01-01")))
+	require.NotEqual(t, h, PlaintextHash(other, []byte("1970-01-01")))
+	require.NotEqual(t, h, PlaintextHash(key, []byte("1970-01-02")))
+	unkeyed := sha256.Sum256([]byte("1970-01-01"))
+	require.NotEqual(t, unkeyed[:], h)
+}
+
+func TestNewKeyEmptyID(t *testing.T) {
+	_, err := NewKey("")
+	require.EqualError(t, err, "key ID must not be empty")
+}
+
+func TestOpenFailures(t *testing.T) {
+	key, err := NewKey("k")
+	require.NoError(t, err)
+	other, err := NewKey("k")
+	require.NoError(t, err)
+
+	seal := func() *Envelope {
+		env, err := Seal(key, []byte("value"))
+		require.NoError(t, err)
+		return env
+	}
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		_, err := Open(MapKeyStore{}, seal())
+		require.EqualError(t, err, "cannot open envelope: key k not found")
+		require.Equal(t, &KeyNotFoundError{KeyID: "k"}, errors.Cause(err))
+		var knf *KeyNotFoundError
+		require.True(t, stderrors.As(err, &knf))
+		require.Equal(t, "k", knf.KeyID)
+	})
+
+	t.Run("WrongKey", func(t *testing.T) {
+		_, err := Open(MapKeyStore{"k": other}, seal())
+		require.Error(t, err)
+		require.EqualError(t, err, "error decrypting envelope sealed with key k: message authentication failed")
+		require.Equal(t, ErrDecryptionFailed, errors.Cause(err))
+		require.True(t, stderrors.Is(err, ErrDecryptionFailed))
+		require.False(t, stderrors.Is(err, ErrHashMismatch))
+	})
+
+	t.Run("MismatchedKeyID", func(t *testing.T) {
+		_, err := Open(MapKeyStore{"k": &Key{ID: "j", Secret: key.Secret}}, seal())
+		require.EqualError(t, err, "key store returned key j for key ID k")
+	})
+
+	t.Run("SwappedHash", func(t *testing.T) {
+		env := seal()
+		env.PlaintextHash = PlaintextHash(key, []byte("other value"))
+		_, err := Open(MapKeyStore{"k": key}, env)
+		require.Equal(t, ErrDecryptionFailed, errors.Cause(err))
+	})
+
+	t.Run("DishonestSealer", func(t *testing.T) {
+		// a holder of the key can authenticate a hash that the plaintext
+		// does not match
+		env := seal()
+		env.PlaintextHash = PlaintextHash(key, []byte("other value"))
+		aead, err := newAEAD(key)
+		require.NoError(t, err)
+		env.Ciphertext = aead.Seal(nil, env.Nonce, []byte("value"), env.additionalData())
+		_, err = Open(MapKeyStore{"k": key}, env)
+		require.EqualError(t, err, "error verifying envelope sealed with key k: plaintext does not match envelope hash")
+		require.Equal(t, ErrHashMismatch, errors.Cause(err))
+		require.True(t, stderrors.Is(err, ErrHashMismatch))
+	})
+
+	t.Run("TamperedCiphertext", func(t *testing.T) {
+		env := seal()
+		env.Ciphertext[0] ^= 0xff
+		_, err := Open(MapKeyStore{"k": key}, env)
+		require.Equal(t, ErrDecryptionFailed, errors.Cause(err))
+	})
+
+	t.Run("BadNonce", func(t *testing.T) {
+		env := seal()
+		env.Nonce = env.Nonce[1:]
+		_, err := Open(MapKeyStore{"k": key}, env)
+		require.EqualError(t, err, "invalid nonce length 11")
+	})
+
+	t.Run("BadKeySize", func(t *testing.T) {
+		_, err := Open(MapKeyStore{"k": &Key{ID: "k", Secret: []byte("short")}}, seal())
+		require.EqualError(t, err, "invalid key size 5 for key k, expected 32")
+	})
+}
+
+func TestUnmarshalFailures(t *testing.T) {
+	_, err := Unmarshal([]byte("garbage"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error unmarshaling envelope")
+
+	key, err := NewKey("k")
+	require.NoError(t, err)
+	env, err := Seal(key, []byte("value"))
+	require.NoError(t, err)
+	raw, err := env.Bytes()
+	require.NoError(t, err)
+
+	_, err = Unmarshal(append(raw, 0))
+	require.EqualError(t, err, "1 trailing bytes after envelope")
+}
@@ -0,0 +1,210 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package envelope
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/gdpr/internal/errwrap"
+	"github.com/pkg/errors"
+)
+
+// Keyring holds the successive versions of a named key. New values are
+// sealed under the current version, and their plaintext hashes are keyed by
+// it, so rotating the keyring re-keys all future writes, while every
+// earlier version is kept so that envelopes and hashes produced under it,
+// which record the versioned key ID, remain verifiable.
+type Keyring struct {
+	mutex    sync.RWMutex
+	name     string
+	versions []*KeyVersion
+	store    VersionStore
+	now      func() time.Time
+}
+
+// KeyVersion is a version of a keyring's key along with the time it was
+// created, which drives RotateIfOlderThan.
+type KeyVersion struct {
+	Key     *Key
+	Created time.Time
+}
+
+// VersionStore persists the versions of keyrings, so that a keyring
+// survives restarts and all peers of a channel can hold the same versions.
+// It may be backed by a database, a file protected like the MSP keystore,
+// or an HSM through BCCSP.
+type VersionStore interface {
+	// Versions returns the stored versions of the named keyring, in order.
+	Versions(name string) ([]*KeyVersion, error)
+	// PutVersion stores a new version of the named keyring. The version only
+	// becomes current once it has been stored.
+	PutVersion(name string, v *KeyVersion) error
+}
+
+// NewKeyring returns a keyring for name holding a freshly generated first
+// version. The keyring only lives in memory, so its versions are lost with
+// the process; use OpenKeyring for keys that outlive it.
+func NewKeyring(name string) (*Keyring, error) {
+	kr, err := newKeyring(name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := kr.Rotate(); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// LoadKeyring returns a keyring for name holding existing versions, for
+// instance ones distributed to the peers of a channel. The versions must
+// carry the IDs of versions 1 to len(versions) of name, in order, and must
+// not have been created out of order. The last one is current. Versions
+// created by rotating the returned keyring are not persisted.
+func LoadKeyring(name string, versions []*KeyVersion) (*Keyring, error) {
+	if len(versions) == 0 {
+		return nil, errors.Errorf("no versions to load into keyring %s", name)
+	}
+	return newKeyring(name, versions, nil)
+}
+
+// OpenKeyring returns the keyring for name holding the versions in store.
+// If store holds none yet, a first version is generated and stored. Every
+// version created by a later rotation is stored before it becomes current.
+func OpenKeyring(name string, store VersionStore) (*Keyring, error) {
+	versions, err := store.Versions(name)
+	if err != nil {
+		return nil, errwrap.WithMessagef(err, "error loading versions of keyring %s", name)
+	}
+	kr, err := newKeyring(name, versions, store)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		if _, err := kr.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return kr, nil
+}
+
+func newKeyring(name string, versions []*KeyVersion, store VersionStore) (*Keyring, error) {
+	if name == "" || strings.Contains(name, "/") {
+		return nil, errors.Errorf("invalid keyring name %q", name)
+	}
+	for i, v := range versions {
+		if v == nil || v.Key == nil {
+			return nil, errors.Errorf("version %d of keyring %s is missing its key", i+1, name)
+		}
+		if v.Key.ID != KeyID(name, i+1) {
+			return nil, errors.Errorf("version %d of keyring %s has key ID %s, expected %s", i+1, name, v.Key.ID, KeyID(name, i+1))
+		}
+		if len(v.Key.Secret) != KeySize {
+			return nil, errors.Errorf("invalid key size %d for key %s, expected %d", len(v.Key.Secret), v.Key.ID, KeySize)
+		}
+		if i > 0 && v.Created.Before(versions[i-1].Created) {
+			return nil, errors.Errorf("key %s was created before the version preceding it", v.Key.ID)
+		}
+	}
+	return &Keyring{
+		name:     name,
+		versions: append([]*KeyVersion{}, versions...),
+		store:    store,
+		now:      time.Now,
+	}, nil
+}
+
+// KeyID returns the ID of the given version of the named key.
+func KeyID(name string, version int) string {
+	return fmt.Sprintf("%s/v%d", name, version)
+}
+
+// ParseKeyID splits a versioned key ID into its name and version. Only the
+// canonical form produced by KeyID is accepted, so that every version has
+// exactly one ID.
+func ParseKeyID(id string) (string, int, error) {
+	i := strings.LastIndex(id, "/v")
+	if i <= 0 {
+		return "", 0, errors.Errorf("key ID %s is not versioned", id)
+	}
+	version, err := strconv.Atoi(id[i+2:])
+	if err != nil || version < 1 || KeyID(id[:i], version) != id {
+		return "", 0, errors.Errorf("key ID %s has an invalid version", id)
+	}
+	return id[:i], version, nil
+}
+
+// Current returns the version new values must be sealed under.
+func (kr *Keyring) Current() *Key {
+	kr.mutex.RLock()
+	defer kr.mutex.RUnlock()
+	return kr.versions[len(kr.versions)-1].Key
+}
+
+// Rotate generates a new version and makes it current. If the keyring has a
+// store, the version is stored first, and the keyring is left unchanged if
+// that fails.
+func (kr *Keyring) Rotate() (*Key, error) {
+	kr.mutex.Lock()
+	defer kr.mutex.Unlock()
+	return kr.rotate()
+}
+
+// RotateIfOlderThan rotates the keyring if the current version was created
+// more than maxAge ago. It is meant to be called periodically by whatever
+// schedules rotation, and reports whether a rotation happened.
+func (kr *Keyring) RotateIfOlderThan(maxAge time.Duration) (bool, error) {
+	kr.mutex.Lock()
+	defer kr.mutex.Unlock()
+	if kr.now().Sub(kr.versions[len(kr.versions)-1].Created) <= maxAge {
+		return false, nil
+	}
+	if _, err := kr.rotate(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Key implements KeyStore, resolving any version of the keyring's key.
+func (kr *Keyring) Key(id string) (*Key, error) {
+	name, version, err := ParseKeyID(id)
+	if err != nil {
+		return nil, err
+	}
+	kr.mutex.RLock()
+	defer kr.mutex.RUnlock()
+	if name != kr.name || version > len(kr.versions) {
+		return nil, &KeyNotFoundError{KeyID: id}
+	}
+	return kr.versions[version-1].Key, nil
+}
+
+// Versions returns the versions of the keyring, oldest first, so that they
+// can be exported to other peers or to another store.
+func (kr *Keyring) Versions() []*KeyVersion {
+	kr.mutex.RLock()
+	defer kr.mutex.RUnlock()
+	return append([]*KeyVersion{}, kr.versions...)
+}
+
+func (kr *Keyring) rotate() (*Key, error) {
+	key, err := NewKey(KeyID(kr.name, len(kr.versions)+1))
+	if err != nil {
+		return nil, err
+	}
+	v := &KeyVersion{Key: key, Created: kr.now()}
+	if kr.store != nil {
+		if err := kr.store.PutVersion(kr.name, v); err != nil {
+			return nil, errwrap.WithMessagef(err, "error storing key %s", key.ID)
+		}
+	}
+	kr.versions = append(kr.versions, v)
+	return key, nil
+}
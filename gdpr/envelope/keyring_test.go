@@ -0,0 +1,180 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package envelope
+
+import (
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyID(t *testing.T) {
+	require.Equal(t, "mychannel.pii/v3", KeyID("mychannel.pii", 3))
+
+	name, version, err := ParseKeyID("mychannel.pii/v3")
+	require.NoError(t, err)
+	require.Equal(t, "mychannel.pii", name)
+	require.Equal(t, 3, version)
+
+	for _, id := range []string{"plain", "/v1", "name/vx", "name/v0", "name/v-1", "name/v01", "name/v+1", "name/v 1"} {
+		_, _, err := ParseKeyID(id)
+		require.Error(t, err, "key ID %s", id)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	kr, err := NewKeyring("salts")
+	require.NoError(t, err)
+	v1 := kr.Current()
+	require.Equal(t, "salts/v1", v1.ID)
+
+	old, err := Seal(v1, []byte("written before rotation"))
+	require.NoError(t, err)
+
+	v2, err := kr.Rotate()
+	require.NoError(t, err)
+	require.Equal(t, "salts/v2", v2.ID)
+	require.Equal(t, v2, kr.Current())
+	require.NotEqual(t, v1.Secret, v2.Secret)
+
+	fresh, err := Seal(kr.Current(), []byte("written after rotation"))
+	require.NoError(t, err)
+	require.Equal(t, "salts/v2", fresh.KeyID)
+
+	plaintext, err := Open(kr, old)
+	require.NoError(t, err)
+	require.Equal(t, []byte("written before rotation"), plaintext)
+	plaintext, err = Open(kr, fresh)
+	require.NoError(t, err)
+	require.Equal(t, []byte("written after rotation"), plaintext)
+}
+
+func TestKeyringLookupErrors(t *testing.T) {
+	kr, err := NewKeyring("salts")
+	require.NoError(t, err)
+
+	_, err = kr.Key("salts/v2")
+	require.EqualError(t, err, "key salts/v2 not found")
+	require.IsType(t, &KeyNotFoundError{}, err)
+	_, err = kr.Key("other/v1")
+	require.EqualError(t, err, "key other/v1 not found")
+	_, err = kr.Key("salts")
+	require.EqualError(t, err, "key ID salts is not versioned")
+	_, err = kr.Key("salts/v01")
+	require.EqualError(t, err, "key ID salts/v01 has an invalid version")
+
+	_, err = NewKeyring("")
+	require.EqualError(t, err, `invalid keyring name ""`)
+	_, err = NewKeyring("a/b")
+	require.EqualError(t, err, `invalid keyring name "a/b"`)
+}
+
+func TestRotateIfOlderThan(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	kr := &Keyring{name: "hmac", now: func() time.Time { return now }}
+	_, err := kr.Rotate()
+	require.NoError(t, err)
+
+	now = now.Add(24 * time.Hour)
+	rotated, err := kr.RotateIfOlderThan(30 * 24 * time.Hour)
+	require.NoError(t, err)
+	require.False(t, rotated)
+	require.Equal(t, "hmac/v1", kr.Current().ID)
+
+	now = now.Add(30 * 24 * time.Hour)
+	rotated, err = kr.RotateIfOlderThan(30 * 24 * time.Hour)
+	require.NoError(t, err)
+	require.True(t, rotated)
+	require.Equal(t, "hmac/v2", kr.Current().ID)
+
+	rotated, err = kr.RotateIfOlderThan(30 * 24 * time.Hour)
+	require.NoError(t, err)
+	require.False(t, rotated)
+}
+
+type memVersionStore struct {
+	versions map[string][]*KeyVersion
+	putErr   error
+}
+
+func (m *memVersionStore) Versions(name string) ([]*KeyVersion, error) {
+	return m.versions[name], nil
+}
+
+func (m *memVersionStore) PutVersion(name string, v *KeyVersion) error {
+	if m.putErr != nil {
+		return m.putErr
+	}
+	m.versions[name] = append(m.versions[name], v)
+	return nil
+}
+
+func TestOpenKeyringSurvivesRestart(t *testing.T) {
+	store := &memVersionStore{versions: map[string][]*KeyVersion{}}
+	kr, err := OpenKeyring("pii", store)
+	require.NoError(t, err)
+	require.Len(t, store.versions["pii"], 1)
+
+	old, err := Seal(kr.Current(), []byte("written under v1"))
+	require.NoError(t, err)
+	_, err = kr.Rotate()
+	require.NoError(t, err)
+	require.Len(t, store.versions["pii"], 2)
+
+	// a restarted peer opens the keyring from the same store
+	restarted, err := OpenKeyring("pii", store)
+	require.NoError(t, err)
+	require.Equal(t, kr.Versions(), restarted.Versions())
+	require.Equal(t, "pii/v2", restarted.Current().ID)
+	plaintext, err := Open(restarted, old)
+	require.NoError(t, err)
+	require.Equal(t, []byte("written under v1"), plaintext)
+
+	store.putErr = errors.New("disk full")
+	_, err = restarted.Rotate()
+	require.EqualError(t, err, "error storing key pii/v3: disk full")
+	require.True(t, stderrors.Is(err, store.putErr))
+	require.Equal(t, "pii/v2", restarted.Current().ID)
+
+	_, err = OpenKeyring("other", store)
+	require.EqualError(t, err, "error storing key other/v1: disk full")
+}
+
+func TestLoadKeyring(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	v1, err := NewKey("mychannel/v1")
+	require.NoError(t, err)
+	v2, err := NewKey("mychannel/v2")
+	require.NoError(t, err)
+	versions := []*KeyVersion{{Key: v1, Created: created}, {Key: v2, Created: created.Add(time.Hour)}}
+
+	// two peers loading the same versions hold the same channel key
+	peer1, err := LoadKeyring("mychannel", versions)
+	require.NoError(t, err)
+	peer2, err := LoadKeyring("mychannel", versions)
+	require.NoError(t, err)
+	require.Equal(t, v2, peer1.Current())
+	env, err := Seal(peer1.Current(), []byte("value"))
+	require.NoError(t, err)
+	plaintext, err := Open(peer2, env)
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), plaintext)
+
+	_, err = LoadKeyring("mychannel", nil)
+	require.EqualError(t, err, "no versions to load into keyring mychannel")
+	_, err = LoadKeyring("mychannel", []*KeyVersion{{Key: v2}})
+	require.EqualError(t, err, "version 1 of keyring mychannel has key ID mychannel/v2, expected mychannel/v1")
+	_, err = LoadKeyring("mychannel", []*KeyVersion{{}})
+	require.EqualError(t, err, "version 1 of keyring mychannel is missing its key")
+	_, err = LoadKeyring("mychannel", []*KeyVersion{{Key: &Key{ID: "mychannel/v1", Secret: []byte("short")}}})
+	require.EqualError(t, err, "invalid key size 5 for key mychannel/v1, expected 32")
+	_, err = LoadKeyring("mychannel", []*KeyVersion{versions[0], {Key: v2, Created: created.Add(-time.Hour)}})
+	require.EqualError(t, err, "key mychannel/v2 was created before the version preceding it")
+}
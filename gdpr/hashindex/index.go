@@ -0,0 +1,188 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package hashindex maintains, per channel, an index from the hash of a
+// written value to every ledger location that wrote it, so that erasure and
+// audits can find all occurrences of a value without scanning the chain.
+// Values are hashed under a key, the same way envelope commits plaintext
+// hashes, so that the index does not hold hashes of personal data that
+// anyone with access to the database could test guesses against, and the
+// entries of an erased value are removed once it has been erased.
+package hashindex
+
+import (
+	"crypto/sha256"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/ledger/dataformat"
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/gdpr/blockindex"
+	"github.com/hyperledger/fabric/gdpr/blockscan"
+	"github.com/hyperledger/fabric/gdpr/envelope"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("gdpr.hashindex")
+
+// Location identifies a single key write in the ledger.
+type Location struct {
+	BlockNum  uint64
+	TxNum     uint64
+	Namespace string
+	Key       string
+}
+
+// ValueHash returns the hash under which a written value is indexed by an
+// index keyed with key. It is the plaintext hash that envelope commits for
+// the value sealed under the same key.
+func ValueHash(key *envelope.Key, value []byte) []byte {
+	return envelope.PlaintextHash(key, value)
+}
+
+// Provider provides handles to the hash index of each channel.
+type Provider struct {
+	leveldbProvider *leveldbhelper.Provider
+}
+
+// NewProvider instantiates a Provider storing its data at path.
+func NewProvider(path string) (*Provider, error) {
+	logger.Debugf("constructing hash index provider dbPath=%s", path)
+	levelDBProvider, err := leveldbhelper.NewProvider(
+		&leveldbhelper.Conf{
+			DBPath:         path,
+			ExpectedFormat: dataformat.CurrentFormat,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{leveldbProvider: levelDBProvider}, nil
+}
+
+// GetIndex returns the index of the named channel, which hashes values
+// under key. The same key must be used every time the index is opened.
+func (p *Provider) GetIndex(channel string, key *envelope.Key) (*Index, error) {
+	if key == nil || len(key.Secret) != envelope.KeySize {
+		return nil, errors.Errorf("invalid key for the hash index of channel [%s]", channel)
+	}
+	levelDB := p.leveldbProvider.GetDBHandle(channel)
+	return &Index{
+		levelDB:   levelDB,
+		savepoint: blockindex.NewSavepoint(levelDB, channel, "hash index"),
+		name:      channel,
+		key:       key,
+	}, nil
+}
+
+// Drop drops the index of the named channel.
+func (p *Provider) Drop(channel string) error {
+	return p.leveldbProvider.Drop(channel)
+}
+
+// Close closes the underlying db.
+func (p *Provider) Close() {
+	p.leveldbProvider.Close()
+}
+
+// Index is the hash index of a single channel.
+type Index struct {
+	levelDB   *leveldbhelper.DBHandle
+	savepoint *blockindex.Savepoint
+	name      string
+	key       *envelope.Key
+}
+
+// Commit indexes the values written by the valid transactions of block.
+// Deletes are not indexed since they carry no value. Blocks are committed
+// as described for blockindex.Savepoint.
+func (i *Index) Commit(block *common.Block) error {
+	blockNum := block.Header.Number
+	if commit, err := i.savepoint.Check(blockNum); err != nil || !commit {
+		return err
+	}
+
+	batch := i.levelDB.NewUpdateBatch()
+	err := blockscan.Writes(block, func(w *blockscan.Write) error {
+		if w.IsDelete {
+			return nil
+		}
+		loc := &Location{BlockNum: w.BlockNum, TxNum: w.TxNum, Namespace: w.Namespace, Key: w.Key}
+		batch.Put(constructLocationKey(ValueHash(i.key, w.Value), loc), emptyValue)
+		return nil
+	})
+	if err != nil {
+		return errors.WithMessagef(err, "error indexing block [%d]", blockNum)
+	}
+	i.savepoint.Update(batch, blockNum)
+
+	if err := i.levelDB.WriteBatch(batch, true); err != nil {
+		return err
+	}
+	logger.Debugf("Channel [%s]: Updates committed to hash index for blockNo [%d]", i.name, blockNum)
+	return nil
+}
+
+// Hash returns the hash under which the index records value.
+func (i *Index) Hash(value []byte) []byte {
+	return ValueHash(i.key, value)
+}
+
+// Lookup returns every location that wrote a value with the given hash,
+// ordered by height.
+func (i *Index) Lookup(hash []byte) ([]*Location, error) {
+	if err := checkHashLength(hash); err != nil {
+		return nil, err
+	}
+	start, end := constructLookupRange(hash)
+	itr, err := i.levelDB.GetIterator(start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer itr.Release()
+
+	var locations []*Location
+	for itr.Next() {
+		loc, err := decodeLocationKey(itr.Key(), len(hash))
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, loc)
+	}
+	return locations, itr.Error()
+}
+
+// Remove removes the given locations from the entries of hash. It is called
+// once the value written at those locations has been erased, so that the
+// index no longer links the value to them. Locations that are not recorded
+// for hash are ignored.
+func (i *Index) Remove(hash []byte, locations ...*Location) error {
+	if err := checkHashLength(hash); err != nil {
+		return err
+	}
+	batch := i.levelDB.NewUpdateBatch()
+	for _, loc := range locations {
+		batch.Delete(constructLocationKey(hash, loc))
+	}
+	if err := i.levelDB.WriteBatch(batch, true); err != nil {
+		return err
+	}
+	logger.Debugf("Channel [%s]: Removed %d locations from hash index", i.name, len(locations))
+	return nil
+}
+
+// LastCommittedBlock returns the number of the last block indexed. The
+// boolean is false if no block has been indexed yet.
+func (i *Index) LastCommittedBlock() (uint64, bool, error) {
+	return i.savepoint.Get()
+}
+
+func checkHashLength(hash []byte) error {
+	if len(hash) != sha256.Size {
+		return errors.Errorf("invalid hash length %d, expected %d", len(hash), sha256.Size)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package hashindex
+
+import (
+	"context"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/gdpr/blockindex/testutil"
+	"github.com/hyperledger/fabric/gdpr/envelope"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestIndex(t *testing.T) (*Index, func()) {
+	path, err := ioutil.TempDir("", "hashindex")
+	require.NoError(t, err)
+	p, err := NewProvider(path)
+	require.NoError(t, err)
+	key, err := envelope.NewKey("mychannel/hashindex")
+	require.NoError(t, err)
+	idx, err := p.GetIndex("mychannel", key)
+	require.NoError(t, err)
+	return idx, func() {
+		p.Close()
+		os.RemoveAll(path)
+	}
+}
+
+func constructBlock(t *testing.T, blockNum uint64, txs ...map[string]string) *common.Block {
+	return testutil.ConstructBlock(t, blockNum, "ns", txs...)
+}
+
+func TestCommitLookup(t *testing.T) {
+	idx, cleanup := newTestIndex(t)
+	defer cleanup()
+
+	_, ok, err := idx.LastCommittedBlock()
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, idx.Commit(constructBlock(t, 0, map[string]string{"k1": "secret", "k2": "other"})))
+	require.NoError(t, idx.Commit(constructBlock(t, 1,
+		map[string]string{"k3": "secret", "k1": ""},
+		map[string]string{"k\x00composite": "secret"},
+	)))
+
+	locations, err := idx.Lookup(idx.Hash([]byte("secret")))
+	require.NoError(t, err)
+	require.Equal(t, []*Location{
+		{BlockNum: 0, TxNum: 0, Namespace: "ns", Key: "k1"},
+		{BlockNum: 1, TxNum: 0, Namespace: "ns", Key: "k3"},
+		{BlockNum: 1, TxNum: 1, Namespace: "ns", Key: "k\x00composite"},
+	}, locations)
+
+	locations, err = idx.Lookup(idx.Hash([]byte("missing")))
+	require.NoError(t, err)
+	require.Empty(t, locations)
+
+	_, err = idx.Lookup([]byte("short"))
+	require.EqualError(t, err, "invalid hash length 5, expected 32")
+
+	savepoint, ok, err := idx.LastCommittedBlock()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(1), savepoint)
+}
+
+func TestHashIsKeyed(t *testing.T) {
+	idx, cleanup := newTestIndex(t)
+	defer cleanup()
+
+	require.Equal(t, envelope.PlaintextHash(idx.key, []byte("secret")), idx.Hash([]byte("secret")))
+	unkeyed := sha256.Sum256([]byte("secret"))
+	require.NotEqual(t, unkeyed[:], idx.Hash([]byte("secret")))
+
+	require.NoError(t, idx.Commit(constructBlock(t, 0, map[string]string{"k1": "secret"})))
+	locations, err := idx.Lookup(unkeyed[:])
+	require.NoError(t, err)
+	require.Empty(t, locations)
+
+	path, err := ioutil.TempDir("", "hashindex")
+	require.NoError(t, err)
+	defer os.RemoveAll(path)
+	p, err := NewProvider(path)
+	require.NoError(t, err)
+	defer p.Close()
+	_, err = p.GetIndex("mychannel", nil)
+	require.EqualError(t, err, "invalid key for the hash index of channel [mychannel]")
+	_, err = p.GetIndex("mychannel", &envelope.Key{ID: "k", Secret: []byte("short")})
+	require.EqualError(t, err, "invalid key for the hash index of channel [mychannel]")
+}
+
+func TestRemove(t *testing.T) {
+	idx, cleanup := newTestIndex(t)
+	defer cleanup()
+
+	require.NoError(t, idx.Commit(constructBlock(t, 0, map[string]string{"k1": "secret", "k2": "other"})))
+	require.NoError(t, idx.Commit(constructBlock(t, 1, map[string]string{"k3": "secret"})))
+
+	hash := idx.Hash([]byte("secret"))
+	require.NoError(t, idx.Remove(hash,
+		&Location{BlockNum: 0, TxNum: 0, Namespace: "ns", Key: "k1"},
+		&Location{BlockNum: 5, TxNum: 0, Namespace: "ns", Key: "unrecorded"},
+	))
+	locations, err := idx.Lookup(hash)
+	require.NoError(t, err)
+	require.Equal(t, []*Location{{BlockNum: 1, TxNum: 0, Namespace: "ns", Key: "k3"}}, locations)
+
+	require.NoError(t, idx.Remove(hash, locations...))
+	locations, err = idx.Lookup(hash)
+	require.NoError(t, err)
+	require.Empty(t, locations)
+
+	// other values are unaffected
+	locations, err = idx.Lookup(idx.Hash([]byte("other")))
+	require.NoError(t, err)
+	require.Len(t, locations, 1)
+
+	err = idx.Remove([]byte("short"))
+	require.EqualError(t, err, "invalid hash length 5, expected 32")
+}
+
+func TestCommitIgnoresIndexedBlocks(t *testing.T) {
+	idx, cleanup := newTestIndex(t)
+	defer cleanup()
+
+	require.NoError(t, idx.Commit(constructBlock(t, 0, map[string]string{"k1": "v"})))
+	require.NoError(t, idx.Commit(constructBlock(t, 0, map[string]string{"k2": "v"})))
+
+	locations, err := idx.Lookup(idx.Hash([]byte("v")))
+	require.NoError(t, err)
+	require.Len(t, locations, 1)
+	require.Equal(t, "k1", locations[0].Key)
+}
+
+func TestCommitRejectsGaps(t *testing.T) {
+	idx, cleanup := newTestIndex(t)
+	defer cleanup()
+
+	err := idx.Commit(constructBlock(t, 1, map[string]string{"k1": "v"}))
+	require.EqualError(t, err, "cannot commit block [1] to hash index, expected block [0]")
+
+	require.NoError(t, idx.Commit(constructBlock(t, 0, map[string]string{"k0": "v"})))
+	err = idx.Commit(constructBlock(t, 2, map[string]string{"k2": "v"}))
+	require.EqualError(t, err, "cannot commit block [2] to hash index, expected block [1]")
+
+	// the savepoint stays put, so a rebuild still indexes block 1
+	savepoint, ok, err := idx.LastCommittedBlock()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Zero(t, savepoint)
+}
+
+func TestCommitBadBlock(t *testing.T) {
+	idx, cleanup := newTestIndex(t)
+	defer cleanup()
+
+	block := constructBlock(t, 0, map[string]string{"k1": "v"})
+	block.Data.Data = append(block.Data.Data, []byte("garbage"))
+	err := idx.Commit(block)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error indexing block [0]")
+
+	_, ok, err := idx.LastCommittedBlock()
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestRebuild(t *testing.T) {
+	idx, cleanup := newTestIndex(t)
+	defer cleanup()
+
+	store := &testutil.BlockStore{
+		Blocks: []*common.Block{
+			constructBlock(t, 0, map[string]string{"k0": "v"}),
+			constructBlock(t, 1, map[string]string{"k1": "v"}),
+			constructBlock(t, 2, map[string]string{"k2": "v"}),
+		},
+		FailAt:  1,
+		FailErr: errors.New("disk on fire"),
+	}
+
+	n, err := Rebuild(context.Background(), idx, store)
+	require.EqualError(t, err, "error retrieving block [1]: disk on fire")
+	require.Equal(t, uint64(1), n)
+
+	store.FailErr = nil
+	n, err = Rebuild(context.Background(), idx, store)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), n)
+
+	locations, err := idx.Lookup(idx.Hash([]byte("v")))
+	require.NoError(t, err)
+	require.Len(t, locations, 3)
+
+	n, err = Rebuild(context.Background(), idx, store)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestRebuildCancellation(t *testing.T) {
+	idx, cleanup := newTestIndex(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	store := &testutil.BlockStore{
+		Blocks: []*common.Block{
+			constructBlock(t, 0, map[string]string{"k0": "v"}),
+			constructBlock(t, 1, map[string]string{"k1": "v"}),
+			constructBlock(t, 2, map[string]string{"k2": "v"}),
+		},
+		Retrieved: func(blockNum uint64) {
+			if blockNum == 1 {
+				cancel()
+			}
+		},
+	}
+
+	n, err := Rebuild(ctx, idx, store)
+	require.EqualError(t, err, "backfill of hash index stopped before block [2]: context canceled")
+	require.Equal(t, context.Canceled, errors.Cause(err))
+	require.Equal(t, uint64(2), n)
+
+	savepoint, ok, err := idx.LastCommittedBlock()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(1), savepoint)
+
+	n, err = Rebuild(ctx, idx, store)
+	require.EqualError(t, err, "backfill of hash index stopped before block [2]: context canceled")
+	require.Zero(t, n)
+
+	n, err = Rebuild(context.Background(), idx, store)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), n)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package hashindex
+
+import (
+	"bytes"
+
+	"github.com/hyperledger/fabric/common/ledger/util"
+	"github.com/pkg/errors"
+)
+
+var (
+	compositeKeySep   = []byte{0x00} // separates the namespace from the key in a location key
+	locationKeyPrefix = []byte{'l'}  // prefix added to location keys
+	emptyValue        = []byte{}     // used as value for keys where only key needs to be stored
+)
+
+// constructLocationKey builds the key of the format
+// prefix~hash~blocknum~trannum~namespace~0x00~key using an order preserving
+// encoding so that lookups return locations ordered by height.
+func constructLocationKey(hash []byte, loc *Location) []byte {
+	k := append([]byte{}, locationKeyPrefix...)
+	k = append(k, hash...)
+	k = append(k, util.EncodeOrderPreservingVarUint64(loc.BlockNum)...)
+	k = append(k, util.EncodeOrderPreservingVarUint64(loc.TxNum)...)
+	k = append(k, []byte(loc.Namespace)...)
+	k = append(k, compositeKeySep...)
+	return append(k, []byte(loc.Key)...)
+}
+
+// constructLookupRange returns the start and end keys of a range scan that
+// covers every location recorded for hash.
+func constructLookupRange(hash []byte) ([]byte, []byte) {
+	start := append(append([]byte{}, locationKeyPrefix...), hash...)
+	end := append(append([]byte{}, start...), 0xff)
+	return start, end
+}
+
+func decodeLocationKey(k []byte, hashLen int) (*Location, error) {
+	rest := k[len(locationKeyPrefix)+hashLen:]
+	blockNum, n, err := util.DecodeOrderPreservingVarUint64(rest)
+	if err != nil {
+		return nil, err
+	}
+	rest = rest[n:]
+	txNum, n, err := util.DecodeOrderPreservingVarUint64(rest)
+	if err != nil {
+		return nil, err
+	}
+	rest = rest[n:]
+	sep := bytes.Index(rest, compositeKeySep)
+	if sep < 0 {
+		return nil, errors.Errorf("location key is missing the namespace separator: %x", k)
+	}
+	return &Location{
+		BlockNum:  blockNum,
+		TxNum:     txNum,
+		Namespace: string(rest[:sep]),
+		Key:       string(rest[sep+1:]),
+	}, nil
+}
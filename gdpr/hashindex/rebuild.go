@@ -0,0 +1,31 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package hashindex
+
+import (
+	"context"
+
+	"github.com/hyperledger/fabric/gdpr/blockindex"
+)
+
+// BackfillJob returns a job that brings the index up to the height of
+// store. It is used to populate the index on peers that joined their
+// channels before the index existed, and to catch up after a crash.
+func (i *Index) BackfillJob(store blockindex.BlockStore) *blockindex.Job {
+	return &blockindex.Job{
+		Channel: i.name,
+		Index:   "hash index",
+		Indexer: i,
+		Store:   store,
+	}
+}
+
+// Rebuild runs the backfill job of idx without throttling. It returns the
+// number of blocks indexed, as described for blockindex.Job.Run.
+func Rebuild(ctx context.Context, idx *Index, store blockindex.BlockStore) (uint64, error) {
+	return idx.BackfillJob(store).Run(ctx)
+}
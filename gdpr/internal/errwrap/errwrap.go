@@ -0,0 +1,56 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package errwrap annotates errors the way errors.WithMessage from
+// github.com/pkg/errors does, but also implements Unwrap, which the vendored
+// version of that package lacks. Errors annotated here can therefore be
+// matched with the standard library's errors.Is and errors.As as well as
+// with errors.Cause.
+package errwrap
+
+import "fmt"
+
+type withMessage struct {
+	cause error
+	msg   string
+}
+
+func (w *withMessage) Error() string { return w.msg + ": " + w.cause.Error() }
+func (w *withMessage) Cause() error  { return w.cause }
+func (w *withMessage) Unwrap() error { return w.cause }
+
+// Format formats the error like errors.WithMessage does, so that %+v also
+// prints the stack trace recorded by the cause.
+func (w *withMessage) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%+v\n", w.cause)
+			fmt.Fprint(s, w.msg)
+			return
+		}
+		fallthrough
+	case 's', 'q':
+		fmt.Fprint(s, w.Error())
+	}
+}
+
+// WithMessage annotates err with msg. It returns nil if err is nil.
+func WithMessage(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return &withMessage{cause: err, msg: msg}
+}
+
+// WithMessagef annotates err with the formatted message. It returns nil if
+// err is nil.
+func WithMessagef(err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return &withMessage{cause: err, msg: fmt.Sprintf(format, args...)}
+}
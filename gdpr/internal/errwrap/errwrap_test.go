@@ -0,0 +1,38 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package errwrap
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type typedError struct{ id string }
+
+func (e *typedError) Error() string { return "typed " + e.id }
+
+func TestWithMessage(t *testing.T) {
+	sentinel := pkgerrors.New("sentinel")
+	err := WithMessagef(WithMessage(sentinel, "inner"), "outer %d", 1)
+	require.EqualError(t, err, "outer 1: inner: sentinel")
+	require.True(t, errors.Is(err, sentinel))
+	require.Equal(t, sentinel, pkgerrors.Cause(err))
+	require.Equal(t, "outer 1: inner: sentinel", fmt.Sprintf("%s", err))
+	require.Contains(t, fmt.Sprintf("%+v", err), "errwrap.TestWithMessage")
+
+	err = WithMessage(&typedError{id: "k"}, "context")
+	var typed *typedError
+	require.True(t, errors.As(err, &typed))
+	require.Equal(t, "k", typed.id)
+
+	require.NoError(t, WithMessage(nil, "context"))
+	require.NoError(t, WithMessagef(nil, "context %d", 1))
+}
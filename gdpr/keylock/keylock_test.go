@@ -0,0 +1,104 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package keylock
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDefaults(t *testing.T) {
+	require.Len(t, New(0).stripes, DefaultStripes)
+	require.Len(t, New(-1).stripes, DefaultStripes)
+	require.Len(t, New(8).stripes, 8)
+}
+
+func TestLockExcludes(t *testing.T) {
+	m := New(16)
+	k := Key{Namespace: "ns", Key: "k"}
+
+	unlock := m.Lock(k)
+	acquired := make(chan struct{})
+	go func() {
+		defer m.RLock(k)()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("reader acquired a key held exclusively")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	<-acquired
+}
+
+func TestRLockShares(t *testing.T) {
+	m := New(16)
+	k := Key{Namespace: "ns", Key: "k"}
+
+	unlock1 := m.RLock(k)
+	unlock2 := m.RLock(k)
+	unlock1()
+	unlock2()
+	m.Lock(k)()
+}
+
+func TestLockSharedStripeOnce(t *testing.T) {
+	// with a single stripe every key collides; locking several keys must not
+	// self-deadlock
+	m := New(1)
+	unlock := m.Lock(Key{"ns", "a"}, Key{"ns", "b"}, Key{"ns", "a"})
+	unlock()
+	m.RLock(Key{"ns", "a"}, Key{"ns", "b"})()
+}
+
+func TestOverlappingLocksDoNotDeadlock(t *testing.T) {
+	m := New(64)
+	var keys []Key
+	for i := 0; i < 20; i++ {
+		keys = append(keys, Key{Namespace: "ns", Key: fmt.Sprintf("k%d", i)})
+	}
+	reversed := make([]Key, len(keys))
+	for i, k := range keys {
+		reversed[len(keys)-1-i] = k
+	}
+
+	counter := 0
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			set := keys
+			if g%2 == 1 {
+				set = reversed
+			}
+			for i := 0; i < 200; i++ {
+				unlock := m.Lock(set...)
+				counter++
+				unlock()
+			}
+		}(g)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("lock acquisition deadlocked")
+	}
+	require.Equal(t, 8*200, counter)
+}
@@ -0,0 +1,186 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package piidetect
+
+import (
+	"regexp"
+)
+
+// Detector decides whether a value likely contains one kind of personal
+// data. Implementations range from regular expressions to calls into a
+// classification model; they must be safe for concurrent use.
+type Detector interface {
+	// Name identifies the detector in findings, logs and metric labels.
+	Name() string
+	// Detect reports whether value likely contains personal data.
+	Detect(value []byte) bool
+}
+
+// RegexpDetector flags values matching a regular expression.
+type RegexpDetector struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// NewRegexpDetector returns a detector named name flagging values that
+// match expr.
+func NewRegexpDetector(name, expr string) (*RegexpDetector, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &RegexpDetector{name: name, re: re}, nil
+}
+
+// Name implements Detector.
+func (d *RegexpDetector) Name() string { return d.name }
+
+// Detect implements Detector.
+func (d *RegexpDetector) Detect(value []byte) bool { return d.re.Match(value) }
+
+var (
+	emailRegexp = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)
+	ssnRegexp   = regexp.MustCompile(`(^|[^0-9])([0-9]{3})-([0-9]{2})-([0-9]{4})($|[^0-9])`)
+	// a run of digits, optionally grouped with single spaces or dashes,
+	// that is not part of a longer run of digits
+	cardRegexp     = regexp.MustCompile(`(^|[^0-9])([0-9](?:[ -]?[0-9])*)`)
+	cardSeparators = regexp.MustCompile(`[ -]`)
+)
+
+// EmailDetector flags values containing an email address.
+type EmailDetector struct{}
+
+// Name implements Detector.
+func (EmailDetector) Name() string { return "email" }
+
+// Detect implements Detector.
+func (EmailDetector) Detect(value []byte) bool { return emailRegexp.Match(value) }
+
+// SSNDetector flags values containing a US social security number in the
+// dashed AAA-GG-SSSS form, excluding numbers the SSA never issues.
+type SSNDetector struct{}
+
+// Name implements Detector.
+func (SSNDetector) Name() string { return "ssn" }
+
+// Detect implements Detector.
+func (SSNDetector) Detect(value []byte) bool {
+	for _, m := range ssnRegexp.FindAllSubmatch(value, -1) {
+		area, group, serial := string(m[2]), string(m[3]), string(m[4])
+		if area == "000" || area == "666" || area[0] == '9' || group == "00" || serial == "0000" {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// CardNumberDetector flags values containing a payment card number: 13 to
+// 19 digits, optionally grouped with spaces or dashes and not part of a
+// longer number, whose issuer prefix and length match a major card scheme
+// and which pass the Luhn check.
+type CardNumberDetector struct{}
+
+// Name implements Detector.
+func (CardNumberDetector) Name() string { return "card_number" }
+
+// Detect implements Detector.
+func (CardNumberDetector) Detect(value []byte) bool {
+	for _, m := range cardRegexp.FindAllSubmatch(value, -1) {
+		run := m[2]
+		if isCardNumber(digitsOf(run)) {
+			return true
+		}
+		// a grouped run may join a card number to an unrelated number,
+		// as in "qty 2 4111111111111111"
+		for _, part := range cardSeparators.Split(string(run), -1) {
+			if isCardNumber([]byte(part)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cardSchemes lists issuer identification number ranges of major card
+// schemes with the number lengths they issue.
+var cardSchemes = []struct {
+	low, high      int // inclusive range of the leading prefixLen digits
+	prefixLen      int
+	minLen, maxLen int
+}{
+	{4, 4, 1, 13, 13},       // Visa
+	{4, 4, 1, 16, 16},       // Visa
+	{4, 4, 1, 19, 19},       // Visa
+	{51, 55, 2, 16, 16},     // Mastercard
+	{2221, 2720, 4, 16, 16}, // Mastercard
+	{34, 34, 2, 15, 15},     // American Express
+	{37, 37, 2, 15, 15},     // American Express
+	{6011, 6011, 4, 16, 19}, // Discover
+	{644, 649, 3, 16, 19},   // Discover
+	{65, 65, 2, 16, 19},     // Discover
+	{300, 305, 3, 14, 19},   // Diners Club
+	{36, 36, 2, 14, 19},     // Diners Club
+	{38, 39, 2, 16, 19},     // Diners Club
+	{3528, 3589, 4, 16, 19}, // JCB
+	{62, 62, 2, 16, 19},     // UnionPay
+}
+
+func isCardNumber(digits []byte) bool {
+	n := len(digits)
+	if n < 13 || n > 19 {
+		return false
+	}
+	for _, scheme := range cardSchemes {
+		prefix := 0
+		for _, c := range digits[:scheme.prefixLen] {
+			prefix = prefix*10 + int(c-'0')
+		}
+		if prefix < scheme.low || prefix > scheme.high {
+			continue
+		}
+		if n >= scheme.minLen && n <= scheme.maxLen {
+			return luhnValid(digits)
+		}
+	}
+	return false
+}
+
+func digitsOf(run []byte) []byte {
+	digits := make([]byte, 0, len(run))
+	for _, c := range run {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, c)
+		}
+	}
+	return digits
+}
+
+func luhnValid(candidate []byte) bool {
+	sum, digits := 0, 0
+	for i := len(candidate) - 1; i >= 0; i-- {
+		c := candidate[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
+
+// DefaultDetectors returns the built-in detectors.
+func DefaultDetectors() []Detector {
+	return []Detector{EmailDetector{}, SSNDetector{}, CardNumberDetector{}}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package piidetect
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailDetector(t *testing.T) {
+	d := EmailDetector{}
+	require.Equal(t, "email", d.Name())
+	for _, v := range []string{"alice@example.com", `{"contact":"bob.smith+tag@mail.example.co.uk"}`} {
+		require.True(t, d.Detect([]byte(v)), v)
+	}
+	for _, v := range []string{"not an email", "user@localhost", "@example.com"} {
+		require.False(t, d.Detect([]byte(v)), v)
+	}
+}
+
+func TestSSNDetector(t *testing.T) {
+	d := SSNDetector{}
+	require.Equal(t, "ssn", d.Name())
+	for _, v := range []string{"123-45-6789", `{"ssn":"078-05-1120"}`, "000-12-3456 and 123-45-6789"} {
+		require.True(t, d.Detect([]byte(v)), v)
+	}
+	for _, v := range []string{"000-12-3456", "666-12-3456", "912-12-3456", "123-00-4567", "123-45-0000", "1123-45-67890", "123456789"} {
+		require.False(t, d.Detect([]byte(v)), v)
+	}
+}
+
+func TestCardNumberDetector(t *testing.T) {
+	d := CardNumberDetector{}
+	require.Equal(t, "card_number", d.Name())
+	for _, v := range []string{
+		"4111111111111111",
+		"4111 1111 1111 1111",
+		`{"pan":"5500-0000-0000-0004"}`,
+		"378282246310005",
+		"2221000000000009",
+		"6011111111111117",
+		"3530111333300000",
+		"qty 2 4111111111111111",
+		"4111111111111112,4111111111111111",
+	} {
+		require.True(t, d.Detect([]byte(v)), v)
+	}
+	for _, v := range []string{
+		"4111111111111112",
+		"1234",
+		"order 1234567890123 shipped",
+		// Luhn-valid epoch timestamps in milli, micro and nanoseconds
+		`{"createdAt":1602678000003}`,
+		`{"createdAt":1602678000000003}`,
+		`{"createdAt":1602678000000000003}`,
+		// a Luhn-valid card number embedded in a longer numeric ID
+		"9994111111111111111006",
+		"id-94111111111111111",
+	} {
+		require.False(t, d.Detect([]byte(v)), v)
+	}
+}
+
+func TestRegexpDetector(t *testing.T) {
+	d, err := NewRegexpDetector("iban", `[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}`)
+	require.NoError(t, err)
+	require.Equal(t, "iban", d.Name())
+	require.True(t, d.Detect([]byte("DE89370400440532013000")))
+	require.False(t, d.Detect([]byte("no account here")))
+
+	_, err = NewRegexpDetector("broken", `(`)
+	require.Error(t, err)
+}
+
+func TestDefaultDetectors(t *testing.T) {
+	var names []string
+	for _, d := range DefaultDetectors() {
+		names = append(names, d.Name())
+	}
+	require.Equal(t, []string{"email", "ssn", "card_number"}, names)
+}
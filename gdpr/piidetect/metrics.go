@@ -0,0 +1,18 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package piidetect
+
+import "github.com/hyperledger/fabric/common/metrics"
+
+var findingsCountOpts = metrics.CounterOpts{
+	Namespace:    "gdpr",
+	Subsystem:    "pii",
+	Name:         "findings_count",
+	Help:         "Number of non-erasable writes flagged as likely containing personal data.",
+	LabelNames:   []string{"channel", "namespace", "detector"},
+	StatsdFormat: "%{#fqname}.%{channel}.%{namespace}.%{detector}",
+}
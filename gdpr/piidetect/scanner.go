@@ -0,0 +1,90 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package piidetect flags committed writes that likely contain personal
+// data but were written to namespaces that are not erasable, so that
+// misconfigured chaincodes are caught before much data accumulates.
+package piidetect
+
+import (
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/metrics"
+	"github.com/hyperledger/fabric/gdpr/blockscan"
+	"github.com/pkg/errors"
+)
+
+var logger = flogging.MustGetLogger("gdpr.piidetect")
+
+// Finding records a write flagged by a detector. It deliberately carries no
+// part of the value. Keys are often built from the same personal data as
+// the value, so callers should treat Key as personal data too.
+type Finding struct {
+	BlockNum  uint64
+	TxNum     uint64
+	TxID      string
+	Namespace string
+	Key       string
+	Detector  string
+}
+
+// Scanner runs detectors over the writes of committed blocks.
+type Scanner struct {
+	channel       string
+	detectors     []Detector
+	isErasable    func(namespace string) bool
+	findingsCount metrics.Counter
+}
+
+// NewScanner returns a scanner for channel. isErasable reports whether a
+// namespace already treats its values as erasable; writes to such
+// namespaces are not scanned. A nil isErasable scans every namespace.
+func NewScanner(channel string, detectors []Detector, isErasable func(namespace string) bool, metricsProvider metrics.Provider) *Scanner {
+	if isErasable == nil {
+		isErasable = func(string) bool { return false }
+	}
+	return &Scanner{
+		channel:       channel,
+		detectors:     detectors,
+		isErasable:    isErasable,
+		findingsCount: metricsProvider.NewCounter(findingsCountOpts),
+	}
+}
+
+// ScanBlock scans the writes of the valid transactions in block and returns
+// a finding for every detector that flags a write. Each finding is logged
+// as a warning and counted. Neither values nor keys are logged, since keys
+// may themselves contain personal data.
+func (s *Scanner) ScanBlock(block *common.Block) ([]*Finding, error) {
+	var findings []*Finding
+	err := blockscan.Writes(block, func(w *blockscan.Write) error {
+		if w.IsDelete || s.isErasable(w.Namespace) {
+			return nil
+		}
+		for _, d := range s.detectors {
+			if !d.Detect(w.Value) {
+				continue
+			}
+			f := &Finding{
+				BlockNum:  w.BlockNum,
+				TxNum:     w.TxNum,
+				TxID:      w.TxID,
+				Namespace: w.Namespace,
+				Key:       w.Key,
+				Detector:  d.Name(),
+			}
+			findings = append(findings, f)
+			s.findingsCount.With("channel", s.channel, "namespace", w.Namespace, "detector", d.Name()).Add(1)
+			logger.Warningf("[%s] Write to non-erasable namespace [%s] by transaction [%s] in block [%d] likely contains personal data (%s)",
+				s.channel, w.Namespace, w.TxID, w.BlockNum, d.Name())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "error scanning block [%d]", block.Header.Number)
+	}
+	return findings, nil
+}
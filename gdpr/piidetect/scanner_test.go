@@ -0,0 +1,63 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package piidetect
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/testutil"
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/hyperledger/fabric/core/ledger/kvledger/txmgmt/rwsetutil"
+	"github.com/hyperledger/fabric/protoutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanBlock(t *testing.T) {
+	b := rwsetutil.NewRWSetBuilder()
+	b.AddToWriteSet("crm", "customer1", []byte(`{"email":"alice@example.com","card":"4111111111111111"}`))
+	b.AddToWriteSet("crm", "customer2", []byte(`{"status":"active"}`))
+	b.AddToWriteSet("crm", "customer3", nil)
+	b.AddToWriteSet("kyc", "doc1", []byte(`{"ssn":"123-45-6789"}`))
+	simRes, err := b.GetTxSimulationResults()
+	require.NoError(t, err)
+	results, err := protoutil.Marshal(simRes.PubSimulationResults)
+	require.NoError(t, err)
+	block := testutil.ConstructBlockWithTxid(t, 5, nil, [][]byte{results}, []string{"tx1"}, false)
+
+	fakeCounter := &metricsfakes.Counter{}
+	fakeCounter.WithReturns(fakeCounter)
+	fakeProvider := &metricsfakes.Provider{}
+	fakeProvider.NewCounterReturns(fakeCounter)
+
+	erasable := func(ns string) bool { return ns == "kyc" }
+	s := NewScanner("mychannel", DefaultDetectors(), erasable, fakeProvider)
+	require.Equal(t, findingsCountOpts, fakeProvider.NewCounterArgsForCall(0))
+
+	findings, err := s.ScanBlock(block)
+	require.NoError(t, err)
+	require.Equal(t, []*Finding{
+		{BlockNum: 5, TxNum: 0, TxID: "tx1", Namespace: "crm", Key: "customer1", Detector: "email"},
+		{BlockNum: 5, TxNum: 0, TxID: "tx1", Namespace: "crm", Key: "customer1", Detector: "card_number"},
+	}, findings)
+
+	require.Equal(t, 2, fakeCounter.AddCallCount())
+	require.Equal(t, []string{"channel", "mychannel", "namespace", "crm", "detector", "email"}, fakeCounter.WithArgsForCall(0))
+	require.Equal(t, []string{"channel", "mychannel", "namespace", "crm", "detector", "card_number"}, fakeCounter.WithArgsForCall(1))
+
+	// without an erasability predicate every namespace is scanned
+	s = NewScanner("mychannel", DefaultDetectors(), nil, fakeProvider)
+	findings, err = s.ScanBlock(block)
+	require.NoError(t, err)
+	require.Len(t, findings, 3)
+	require.Equal(t, "kyc", findings[2].Namespace)
+	require.Equal(t, "ssn", findings[2].Detector)
+
+	block.Data.Data = append(block.Data.Data, []byte("garbage"))
+	_, err = s.ScanBlock(block)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "error scanning block [5]")
+}
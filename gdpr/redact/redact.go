@@ -0,0 +1,230 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package redact replaces individual fields of structured JSON values, so
+// that a value with some erased fields can still be served with its
+// remaining structure intact instead of failing as a whole.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Erased is the value that replaces each redacted field.
+const Erased = "<erased>"
+
+var (
+	validEscapes = strings.NewReplacer("~0", "", "~1", "")
+	unescape     = strings.NewReplacer("~1", "/", "~0", "~")
+	erased       = []byte(`"` + Erased + `"`)
+)
+
+// Redact returns the JSON document value with the element at each of the
+// given JSON pointers (RFC 6901) replaced by the string Erased. Pointers
+// that do not resolve are ignored, since a field may be absent from some
+// versions of a value. The empty pointer redacts the whole document. Only
+// the redacted elements change: the rest of the document is copied byte
+// for byte, so numbers that do not fit a double keep their precision.
+// Surrounding whitespace is dropped.
+func Redact(value []byte, pointers ...string) ([]byte, error) {
+	paths := make([][]string, len(pointers))
+	for i, p := range pointers {
+		path, err := parsePointer(p)
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = path
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(value))
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return nil, errors.Wrap(err, "invalid JSON value")
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid JSON value: unexpected data after top-level value")
+	}
+	p := &parser{buf: raw}
+	doc, err := p.value()
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid JSON value")
+	}
+
+	var targets []*node
+	for _, path := range paths {
+		if n, ok := doc.resolve(path); ok {
+			targets = append(targets, n)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].start < targets[j].start })
+
+	var out []byte
+	last := 0
+	for _, n := range targets {
+		// an element inside one already redacted is gone with it
+		if n.start < last {
+			continue
+		}
+		out = append(append(out, raw[last:n.start]...), erased...)
+		last = n.end
+	}
+	return append(out, raw[last:]...), nil
+}
+
+// node is an element of a JSON document, located by its byte offsets.
+type node struct {
+	start, end int
+	// kind is the first byte of the element, { for objects and [ for arrays
+	kind     byte
+	members  map[string]*node
+	elements []*node
+}
+
+// resolve returns the element at path below n.
+func (n *node) resolve(path []string) (*node, bool) {
+	for _, token := range path {
+		switch n.kind {
+		case '{':
+			child, ok := n.members[token]
+			if !ok {
+				return nil, false
+			}
+			n = child
+		case '[':
+			i, ok := arrayIndex(token, len(n.elements))
+			if !ok {
+				return nil, false
+			}
+			n = n.elements[i]
+		default:
+			return nil, false
+		}
+	}
+	return n, true
+}
+
+// parser locates the elements of a document that encoding/json has
+// already checked to be valid.
+type parser struct {
+	buf []byte
+	pos int
+}
+
+// value parses the element at the current position. Decoding into a map
+// would silently keep the last of duplicate members, so they are rejected.
+func (p *parser) value() (*node, error) {
+	p.skipWhitespace()
+	n := &node{start: p.pos, kind: p.buf[p.pos]}
+	switch n.kind {
+	case '{':
+		n.members = map[string]*node{}
+		p.pos++
+		for p.skipWhitespace(); p.buf[p.pos] != '}'; p.skipWhitespace() {
+			keyStart := p.pos
+			p.skipString()
+			var key string
+			if err := json.Unmarshal(p.buf[keyStart:p.pos], &key); err != nil {
+				return nil, errors.Wrap(err, "error decoding object member name")
+			}
+			if _, ok := n.members[key]; ok {
+				return nil, errors.Errorf("duplicate object member %q", key)
+			}
+			p.skipWhitespace()
+			p.pos++ // ':'
+			child, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			n.members[key] = child
+			p.skipSeparator()
+		}
+		p.pos++
+	case '[':
+		p.pos++
+		for p.skipWhitespace(); p.buf[p.pos] != ']'; p.skipWhitespace() {
+			child, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			n.elements = append(n.elements, child)
+			p.skipSeparator()
+		}
+		p.pos++
+	case '"':
+		p.skipString()
+	default:
+		for p.pos < len(p.buf) && !strings.ContainsRune(",]} \t\r\n", rune(p.buf[p.pos])) {
+			p.pos++
+		}
+	}
+	n.end = p.pos
+	return n, nil
+}
+
+func (p *parser) skipString() {
+	for p.pos++; p.buf[p.pos] != '"'; p.pos++ {
+		if p.buf[p.pos] == '\\' {
+			p.pos++
+		}
+	}
+	p.pos++
+}
+
+func (p *parser) skipSeparator() {
+	p.skipWhitespace()
+	if p.buf[p.pos] == ',' {
+		p.pos++
+	}
+}
+
+func (p *parser) skipWhitespace() {
+	for p.pos < len(p.buf) && strings.ContainsRune(" \t\r\n", rune(p.buf[p.pos])) {
+		p.pos++
+	}
+}
+
+// arrayIndex parses a pointer token as an index into an array of length n.
+// RFC 6901 only allows 0 or a decimal number without leading zeros or sign.
+func arrayIndex(token string, n int) (int, bool) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, false
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i >= n {
+		return 0, false
+	}
+	return i, true
+}
+
+// parsePointer splits a JSON pointer into its unescaped reference tokens.
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, errors.Errorf("invalid JSON pointer %q: must be empty or start with /", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		if strings.Contains(validEscapes.Replace(t), "~") {
+			return nil, errors.Errorf("invalid JSON pointer %q: bad escape in %q", p, t)
+		}
+		tokens[i] = unescape.Replace(t)
+	}
+	return tokens, nil
+}
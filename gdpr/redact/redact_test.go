@@ -0,0 +1,132 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedact(t *testing.T) {
+	value := []byte(`{"id":"customer-17","contact":{"email":"alice@example.com","phone":"555-0100"},"addresses":[{"city":"Zurich"},{"city":"Basel"}],"balance":1E3,"a/b":"slash","m~n":"tilde"}`)
+
+	tests := []struct {
+		name     string
+		pointers []string
+		expected string
+	}{
+		{
+			name:     "NoPointers",
+			expected: string(value),
+		},
+		{
+			name:     "NestedMember",
+			pointers: []string{"/contact/email"},
+			expected: `{"id":"customer-17","contact":{"email":"<erased>","phone":"555-0100"},"addresses":[{"city":"Zurich"},{"city":"Basel"}],"balance":1E3,"a/b":"slash","m~n":"tilde"}`,
+		},
+		{
+			name:     "WholeObject",
+			pointers: []string{"/contact/phone", "/contact", "/contact/email"},
+			expected: `{"id":"customer-17","contact":"<erased>","addresses":[{"city":"Zurich"},{"city":"Basel"}],"balance":1E3,"a/b":"slash","m~n":"tilde"}`,
+		},
+		{
+			name:     "ArrayElement",
+			pointers: []string{"/addresses/1/city", "/balance"},
+			expected: `{"id":"customer-17","contact":{"email":"alice@example.com","phone":"555-0100"},"addresses":[{"city":"Zurich"},{"city":"<erased>"}],"balance":"<erased>","a/b":"slash","m~n":"tilde"}`,
+		},
+		{
+			name:     "EscapedTokens",
+			pointers: []string{"/a~1b", "/m~0n"},
+			expected: `{"id":"customer-17","contact":{"email":"alice@example.com","phone":"555-0100"},"addresses":[{"city":"Zurich"},{"city":"Basel"}],"balance":1E3,"a/b":"<erased>","m~n":"<erased>"}`,
+		},
+		{
+			name:     "UnresolvedPointersIgnored",
+			pointers: []string{"/missing", "/contact/email/deeper", "/addresses/2", "/addresses/01", "/addresses/+1", "/addresses/-1", "/addresses/ 1", "/addresses/-", "/id/0"},
+			expected: string(value),
+		},
+		{
+			name:     "WholeDocument",
+			pointers: []string{"/id", ""},
+			expected: `"<erased>"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Redact(value, tt.pointers...)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(out))
+		})
+	}
+}
+
+func TestRedactPreservesUntouchedBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		pointers []string
+		expected string
+	}{
+		{
+			name:     "LargeInteger",
+			value:    `{"accountId":12345678901234567891,"name":"alice"}`,
+			pointers: []string{"/name"},
+			expected: `{"accountId":12345678901234567891,"name":"<erased>"}`,
+		},
+		{
+			name:     "HighPrecisionDecimal",
+			value:    `{"rate":0.1000000000000000055511151231257827,"iban":"CH93 0076 2011 6238 5295 7"}`,
+			pointers: []string{"/iban"},
+			expected: `{"rate":0.1000000000000000055511151231257827,"iban":"<erased>"}`,
+		},
+		{
+			name:     "Whitespace",
+			value:    " {\n\t\"a\" : [ 1 , {\"b\":\"x\\\"]\"} ] ,\r\n\t\"c\":-0.0e+00 }\n",
+			pointers: []string{"/a/1/b"},
+			expected: "{\n\t\"a\" : [ 1 , {\"b\":\"<erased>\"} ] ,\r\n\t\"c\":-0.0e+00 }",
+		},
+		{
+			name:     "EscapedMemberName",
+			value:    `{"\u0065mail":"alice@example.com","tags":[]}`,
+			pointers: []string{"/email", "/tags/0"},
+			expected: `{"\u0065mail":"<erased>","tags":[]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Redact([]byte(tt.value), tt.pointers...)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(out))
+		})
+	}
+}
+
+func TestRedactErrors(t *testing.T) {
+	_, err := Redact([]byte(`{"a":1}`), "a")
+	require.EqualError(t, err, `invalid JSON pointer "a": must be empty or start with /`)
+
+	_, err = Redact([]byte(`{"a":1}`), "/a~2")
+	require.EqualError(t, err, `invalid JSON pointer "/a~2": bad escape in "a~2"`)
+
+	_, err = Redact([]byte(`{"a":`), "/a")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid JSON value")
+
+	_, err = Redact([]byte(`{"a":1} {}`), "/a")
+	require.EqualError(t, err, "invalid JSON value: unexpected data after top-level value")
+
+	_, err = Redact([]byte(`{"email":"alice@example.com","email":"x"}`), "/email")
+	require.EqualError(t, err, `invalid JSON value: duplicate object member "email"`)
+
+	_, err = Redact([]byte(`[{"k":1,"k":2}]`), "/0/k")
+	require.EqualError(t, err, `invalid JSON value: duplicate object member "k"`)
+
+	_, err = Redact([]byte(`{"email":"alice@example.com","\u0065mail":"x"}`), "/email")
+	require.EqualError(t, err, `invalid JSON value: duplicate object member "email"`)
+}
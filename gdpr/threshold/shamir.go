@@ -0,0 +1,149 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package threshold
+
+import (
+	"crypto/rand"
+
+	"github.com/pkg/errors"
+)
+
+// Share is one share of a secret split with Split. X is the evaluation point
+// of the share and is never zero.
+type Share struct {
+	X     byte
+	Value []byte
+}
+
+// Split splits secret into n shares such that any t of them recover it and
+// fewer than t reveal nothing about it. Each byte of the secret is shared
+// independently with a random polynomial of degree t-1 over GF(2^8).
+func Split(secret []byte, n, t int) ([]Share, error) {
+	switch {
+	case len(secret) == 0:
+		return nil, errors.New("secret must not be empty")
+	case t < 1:
+		return nil, errors.Errorf("threshold must be at least 1, got %d", t)
+	case n < t:
+		return nil, errors.Errorf("number of shares %d is less than threshold %d", n, t)
+	case n > 255:
+		return nil, errors.Errorf("number of shares %d exceeds maximum of 255", n)
+	}
+
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i] = Share{X: byte(i + 1), Value: make([]byte, len(secret))}
+	}
+
+	coefficients := make([]byte, t)
+	for b, s := range secret {
+		coefficients[0] = s
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, errors.Wrap(err, "error generating polynomial coefficients")
+		}
+		for i := range shares {
+			shares[i].Value[b] = evaluate(coefficients, shares[i].X)
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine recovers a secret from shares produced by Split. The caller must
+// supply at least the threshold number of shares; combining fewer yields an
+// unrelated value rather than an error, which is why the recovered secret
+// should always be checked, e.g. by opening an envelope with it.
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares provided")
+	}
+
+	size := len(shares[0].Value)
+	seen := map[byte]struct{}{}
+	for _, s := range shares {
+		if s.X == 0 {
+			return nil, errors.New("share has invalid evaluation point 0")
+		}
+		if _, ok := seen[s.X]; ok {
+			return nil, errors.Errorf("duplicate share for evaluation point %d", s.X)
+		}
+		seen[s.X] = struct{}{}
+		if len(s.Value) != size {
+			return nil, errors.Errorf("share %d has length %d, expected %d", s.X, len(s.Value), size)
+		}
+	}
+
+	// Lagrange basis polynomials evaluated at zero.
+	basis := make([]byte, len(shares))
+	for i, si := range shares {
+		l := byte(1)
+		for j, sj := range shares {
+			if i == j {
+				continue
+			}
+			l = mul(l, div(sj.X, sj.X^si.X))
+		}
+		basis[i] = l
+	}
+
+	secret := make([]byte, size)
+	for b := range secret {
+		var v byte
+		for i, s := range shares {
+			v ^= mul(basis[i], s.Value[b])
+		}
+		secret[b] = v
+	}
+	return secret, nil
+}
+
+func evaluate(coefficients []byte, x byte) byte {
+	// Horner's method
+	var y byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = mul(y, x) ^ coefficients[i]
+	}
+	return y
+}
+
+// Arithmetic in GF(2^8) with the AES reduction polynomial x^8+x^4+x^3+x+1,
+// using log and exp tables over the generator 3.
+var expTable, logTable = buildTables()
+
+func buildTables() (exp [510]byte, log [256]byte) {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i] = x
+		exp[i+255] = x
+		log[x] = byte(i)
+		// multiply by the generator 3 = x + 1
+		hi := x & 0x80
+		x2 := x << 1
+		if hi != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+	return exp, log
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func div(a, b byte) byte {
+	if b == 0 {
+		panic("division by zero")
+	}
+	if a == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+255-int(logTable[b])]
+}
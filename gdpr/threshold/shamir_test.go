@@ -0,0 +1,79 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package threshold
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldArithmetic(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			p := mul(byte(a), byte(b))
+			require.Equal(t, byte(a), div(p, byte(b)), "a=%d b=%d", a, b)
+		}
+	}
+	// 0x53 * 0xca = 0x01 in the AES field
+	require.Equal(t, byte(0x01), mul(0x53, 0xca))
+	require.Equal(t, byte(0), mul(0, 7))
+	require.Equal(t, byte(0), div(0, 7))
+	require.Panics(t, func() { div(1, 0) })
+}
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	shares, err := Split(secret, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+
+	for _, subset := range [][]int{{0, 1, 2}, {2, 3, 4}, {0, 2, 4}, {4, 1, 3}, {0, 1, 2, 3, 4}} {
+		var chosen []Share
+		for _, i := range subset {
+			chosen = append(chosen, shares[i])
+		}
+		recovered, err := Combine(chosen)
+		require.NoError(t, err)
+		require.Equal(t, secret, recovered, "subset %v", subset)
+	}
+
+	recovered, err := Combine(shares[:2])
+	require.NoError(t, err)
+	require.NotEqual(t, secret, recovered)
+}
+
+func TestSplitThresholdOne(t *testing.T) {
+	shares, err := Split([]byte("s"), 3, 1)
+	require.NoError(t, err)
+	for _, s := range shares {
+		require.Equal(t, []byte("s"), s.Value)
+	}
+}
+
+func TestSplitErrors(t *testing.T) {
+	_, err := Split(nil, 3, 2)
+	require.EqualError(t, err, "secret must not be empty")
+	_, err = Split([]byte("s"), 3, 0)
+	require.EqualError(t, err, "threshold must be at least 1, got 0")
+	_, err = Split([]byte("s"), 2, 3)
+	require.EqualError(t, err, "number of shares 2 is less than threshold 3")
+	_, err = Split([]byte("s"), 256, 3)
+	require.EqualError(t, err, "number of shares 256 exceeds maximum of 255")
+}
+
+func TestCombineErrors(t *testing.T) {
+	_, err := Combine(nil)
+	require.EqualError(t, err, "no shares provided")
+	_, err = Combine([]Share{{X: 0, Value: []byte{1}}})
+	require.EqualError(t, err, "share has invalid evaluation point 0")
+	_, err = Combine([]Share{{X: 1, Value: []byte{1}}, {X: 1, Value: []byte{2}}})
+	require.EqualError(t, err, "duplicate share for evaluation point 1")
+	_, err = Combine([]Share{{X: 1, Value: []byte{1}}, {X: 2, Value: []byte{2, 3}}})
+	require.EqualError(t, err, "share 2 has length 2, expected 1")
+}
@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package threshold seals preimages so that recovering the plaintext
+// requires the cooperation of t out of n organizations. The preimage is
+// sealed in an envelope under a fresh data key, and the data key is split
+// into one Shamir share per organization.
+//
+// Shares are returned in the clear; they must be delivered to each
+// organization over a confidential channel, or encrypted to it, before
+// they leave the sealing peer.
+package threshold
+
+import (
+	"sort"
+
+	"github.com/hyperledger/fabric/gdpr/envelope"
+	"github.com/hyperledger/fabric/gdpr/internal/errwrap"
+	"github.com/pkg/errors"
+)
+
+// Seal seals plaintext under a fresh data key identified by keyID and splits
+// that key among orgs such that any t of them can open the envelope.
+func Seal(keyID string, plaintext []byte, orgs []string, t int) (*envelope.Envelope, map[string]Share, error) {
+	if len(orgs) == 0 {
+		return nil, nil, errors.New("no organizations provided")
+	}
+	sorted := append([]string(nil), orgs...)
+	sort.Strings(sorted)
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i] == sorted[i-1] {
+			return nil, nil, errors.Errorf("duplicate organization %s", sorted[i])
+		}
+	}
+
+	key, err := envelope.NewKey(keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	env, err := envelope.Seal(key, plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+	shares, err := Split(key.Secret, len(sorted), t)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "error splitting data key")
+	}
+
+	byOrg := make(map[string]Share, len(sorted))
+	for i, org := range sorted {
+		byOrg[org] = shares[i]
+	}
+	return env, byOrg, nil
+}
+
+// Open recombines the data key from shares and opens the envelope. It fails
+// if fewer than the threshold number of valid shares are supplied, since the
+// recombined key does not authenticate the envelope.
+func Open(env *envelope.Envelope, shares []Share) ([]byte, error) {
+	secret, err := Combine(shares)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error recombining data key")
+	}
+	ks := envelope.MapKeyStore{env.KeyID: &envelope.Key{ID: env.KeyID, Secret: secret}}
+	plaintext, err := envelope.Open(ks, env)
+	if err != nil {
+		return nil, errwrap.WithMessagef(err, "cannot open envelope with %d shares", len(shares))
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,51 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package threshold
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"github.com/hyperledger/fabric/gdpr/envelope"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealOpen(t *testing.T) {
+	orgs := []string{"Org3MSP", "Org1MSP", "Org2MSP"}
+	env, shares, err := Seal("tx1/key1", []byte("personal data"), orgs, 2)
+	require.NoError(t, err)
+	require.Len(t, shares, 3)
+	require.Equal(t, "tx1/key1", env.KeyID)
+
+	plaintext, err := Open(env, []Share{shares["Org1MSP"], shares["Org3MSP"]})
+	require.NoError(t, err)
+	require.Equal(t, []byte("personal data"), plaintext)
+
+	_, err = Open(env, []Share{shares["Org2MSP"]})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cannot open envelope with 1 shares")
+	require.Equal(t, envelope.ErrDecryptionFailed, errors.Cause(err))
+	require.True(t, stderrors.Is(err, envelope.ErrDecryptionFailed))
+
+	_, err = Open(env, nil)
+	require.EqualError(t, err, "error recombining data key: no shares provided")
+}
+
+func TestSealErrors(t *testing.T) {
+	_, _, err := Seal("k", []byte("v"), nil, 1)
+	require.EqualError(t, err, "no organizations provided")
+
+	_, _, err = Seal("k", []byte("v"), []string{"Org1MSP", "Org1MSP"}, 1)
+	require.EqualError(t, err, "duplicate organization Org1MSP")
+
+	_, _, err = Seal("k", []byte("v"), []string{"Org1MSP"}, 2)
+	require.EqualError(t, err, "error splitting data key: number of shares 1 is less than threshold 2")
+
+	_, _, err = Seal("", []byte("v"), []string{"Org1MSP"}, 1)
+	require.EqualError(t, err, "key ID must not be empty")
+}
@@ -0,0 +1,36 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package node
+
+import (
+	"context"
+	"os"
+	"syscall"
+
+	"github.com/hyperledger/fabric/core/ledger/kvledger"
+	"github.com/spf13/cobra"
+)
+
+func compactDBsCmd() *cobra.Command {
+	return nodeCompactCmd
+}
+
+var nodeCompactCmd = &cobra.Command{
+	Use:   "compact-dbs",
+	Short: "Compacts databases.",
+	Long:  "Rewrites the leveldb databases for all the channels so that deleted and overwritten entries no longer remain on disk. When the command is executed, the peer must be offline. On SIGINT or SIGTERM, the command stops after the current batch and leaves the database being rewritten untouched.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config := ledgerConfig()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		handleSignals(addPlatformSignals(map[os.Signal]func(){
+			syscall.SIGINT:  cancel,
+			syscall.SIGTERM: cancel,
+		}))
+		return kvledger.CompactDBs(ctx, config)
+	},
+}
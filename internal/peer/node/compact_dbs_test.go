@@ -0,0 +1,44 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package node
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric/common/ledger/util/leveldbhelper"
+	"github.com/hyperledger/fabric/core/config"
+	"github.com/hyperledger/fabric/core/ledger/kvledger"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompactDBsCmd(t *testing.T) {
+	testPath := "/tmp/hyperledger/test"
+	os.RemoveAll(testPath)
+	viper.Set("peer.fileSystemPath", testPath)
+	defer os.RemoveAll(testPath)
+
+	viper.Set("logging.ledger", "INFO")
+	rootFSPath := filepath.Join(config.GetPath("peer.fileSystemPath"), "ledgersData")
+	historyDBPath := kvledger.HistoryDBPath(rootFSPath)
+
+	db := leveldbhelper.CreateDB(&leveldbhelper.Conf{DBPath: historyDBPath})
+	db.Open()
+	require.NoError(t, db.Put([]byte("key"), []byte("value"), true))
+	db.Close()
+
+	cmd := compactDBsCmd()
+	require.NoError(t, cmd.Execute())
+
+	db.Open()
+	defer db.Close()
+	val, err := db.Get([]byte("key"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), val)
+}
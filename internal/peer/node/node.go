@@ -28,6 +28,7 @@ func Cmd() *cobra.Command {
 	nodeCmd.AddCommand(pauseCmd())
 	nodeCmd.AddCommand(resumeCmd())
 	nodeCmd.AddCommand(rebuildDBsCmd())
+	nodeCmd.AddCommand(compactDBsCmd())
 	nodeCmd.AddCommand(upgradeDBsCmd())
 	return nodeCmd
 }